@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VolumeReclaimRestrictionSpec defines which claims a restriction applies to and the reclaim policies they may request
+type VolumeReclaimRestrictionSpec struct {
+	// StorageClassNames restricts claims for the given StorageClasses.
+	// An empty list matches every StorageClass.
+	// +optional
+	StorageClassNames []string `json:"storageClassNames,omitempty"`
+
+	// NamespaceSelector restricts claims in Namespaces matching the selector.
+	// A nil selector matches every Namespace.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// AllowedReclaimPolicies is the set of reclaim policies matching claims may request. When more than
+	// one restriction matches a claim, only the policies allowed by all of them may be requested.
+	// +kubebuilder:validation:MinItems=1
+	AllowedReclaimPolicies []corev1.PersistentVolumeReclaimPolicy `json:"allowedReclaimPolicies"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=vrrs
+// +kubebuilder:printcolumn:name="Allowed",type=string,JSONPath=`.spec.allowedReclaimPolicies`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// VolumeReclaimRestriction limits the reclaim policies that claims matched by StorageClass and Namespace
+// selector may request
+type VolumeReclaimRestriction struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VolumeReclaimRestrictionSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VolumeReclaimRestrictionList contains a list of VolumeReclaimRestriction
+type VolumeReclaimRestrictionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VolumeReclaimRestriction `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VolumeReclaimRestriction{}, &VolumeReclaimRestrictionList{})
+}
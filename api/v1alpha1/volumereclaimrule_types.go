@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VolumeReclaimRuleSpec defines which Persistent Volumes a rule applies to and the reclaim policy to set
+type VolumeReclaimRuleSpec struct {
+	// StorageClassNames restricts the rule to Persistent Volumes of the given StorageClasses.
+	// An empty list matches every StorageClass.
+	// +optional
+	StorageClassNames []string `json:"storageClassNames,omitempty"`
+
+	// NamespaceSelector restricts the rule to claims in Namespaces matching the selector.
+	// A nil selector matches every Namespace.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// PersistentVolumeClaimSelector restricts the rule to claims matching the selector.
+	// A nil selector matches every claim.
+	// +optional
+	PersistentVolumeClaimSelector *metav1.LabelSelector `json:"persistentVolumeClaimSelector,omitempty"`
+
+	// ReclaimPolicy is the reclaim policy to set on matching Persistent Volumes
+	// +kubebuilder:validation:Enum=Retain;Delete;Recycle
+	ReclaimPolicy corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy"`
+
+	// Priority decides the winning rule when more than one rule matches a Persistent Volume.
+	// Higher values win, ties are broken by rule name.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=vrr
+// +kubebuilder:printcolumn:name="Policy",type=string,JSONPath=`.spec.reclaimPolicy`
+// +kubebuilder:printcolumn:name="Priority",type=integer,JSONPath=`.spec.priority`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// VolumeReclaimRule sets the reclaim policy on Persistent Volumes matched by StorageClass, Namespace
+// and claim selectors
+type VolumeReclaimRule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VolumeReclaimRuleSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VolumeReclaimRuleList contains a list of VolumeReclaimRule
+type VolumeReclaimRuleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VolumeReclaimRule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VolumeReclaimRule{}, &VolumeReclaimRuleList{})
+}
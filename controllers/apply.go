@@ -0,0 +1,290 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"twr.dev/volrec/pkg/config"
+	"twr.dev/volrec/pkg/dryrun"
+	"twr.dev/volrec/pkg/metrics"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// FieldManager is the server-side apply field manager volrec applies Persistent Volume fields as
+const FieldManager = "volrec"
+
+var applyLog = logf.Log.WithName("controllers").WithName("apply")
+
+// legacyFieldManager is the field manager of the fields set by releases of volrec that used client-side updates
+const legacyFieldManager = "manager"
+
+// ownedFields are the Persistent Volume fields owned by a field manager
+type ownedFields struct {
+	labels        map[string]bool
+	annotations   map[string]bool
+	reclaimPolicy bool
+}
+
+// applyPersistentVolume server-side applies the fields volrec manages on pv with the volrec field manager.
+// The applied configuration holds every field volrec already owns plus the fields changed since base, so
+// ownership is kept across the controllers sharing the field manager, and fields removed since base are
+// dropped from it. The resourceVersion of base is applied too, so a stale base fails with a conflict instead
+// of dropping fields another controller just applied.
+//
+// Ownership of the reclaim policy, and of the labels and annotations set by client-side releases of volrec,
+// is forced, since volrec manages them whoever set them first (ie. the provisioner creating the PV). Other
+// labels and annotations aren't forced, one owned by another manager with a different value fails with a
+// field manager conflict (see isFieldManagerConflict). The API call is skipped entirely when nothing changed,
+// in which case false is returned. When dry-run is enabled the change is only added to the dry-run report,
+// and true is returned as if applied.
+func applyPersistentVolume(ctx context.Context, c client.Client, controller string, pv, base *corev1.PersistentVolume) (bool, error) {
+	owned := volrecOwnedFields(base)
+	legacy := managedFields(base, legacyFieldManager, metav1.ManagedFieldsOperationUpdate)
+
+	labels, labelsChanged := appliedValues(owned.labels, base.GetLabels(), pv.GetLabels())
+	annotations, annotationsChanged := appliedValues(owned.annotations, base.GetAnnotations(), pv.GetAnnotations())
+	policyChanged := base.Spec.PersistentVolumeReclaimPolicy != pv.Spec.PersistentVolumeReclaimPolicy
+
+	if !labelsChanged && !annotationsChanged && !policyChanged {
+		metrics.NoopReconciles.WithLabelValues(controller).Inc()
+		dryrun.Forget(pv.Name, controller)
+		return false, nil
+	}
+
+	if config.Get().DryRun {
+		change := dryrun.NewChange(controller, pv, base)
+		applyLog.Info("Dry-run enabled, not applying PV changes", "pv", pv.Name, "controller", controller, "labels", change.Labels, "removed-labels", change.RemovedLabels,
+			"annotations", change.Annotations, "removed-annotations", change.RemovedAnnotations, "reclaim-policy", change.ReclaimPolicy)
+		dryrun.Record(change)
+		metrics.DryRunChanges.WithLabelValues(controller).Inc()
+		return true, nil
+	}
+
+	var policy *corev1.PersistentVolumeReclaimPolicy
+	if owned.reclaimPolicy || policyChanged {
+		policy = &pv.Spec.PersistentVolumeReclaimPolicy
+	}
+
+	// The fields volrec manages are applied with force first, the remaining labels and annotations after
+	claimedLabels, adoptedLabels := claimedValues(labels, owned.labels, legacy.labels)
+	claimedAnnotations, adoptedAnnotations := claimedValues(annotations, owned.annotations, legacy.annotations)
+	forced := policyChanged || adoptedLabels || adoptedAnnotations
+	complete := len(claimedLabels) == len(labels) && len(claimedAnnotations) == len(annotations)
+
+	name, resourceVersion := pv.Name, base.ResourceVersion
+	if forced {
+		data, err := applyConfiguration(name, resourceVersion, claimedLabels, claimedAnnotations, policy)
+		if err != nil {
+			return false, err
+		}
+		if err := patchPersistentVolume(ctx, c, controller, pv, data, client.ForceOwnership); err != nil {
+			return false, err
+		}
+		if complete {
+			return true, nil
+		}
+		resourceVersion = pv.ResourceVersion
+	}
+
+	data, err := applyConfiguration(name, resourceVersion, labels, annotations, policy)
+	if err != nil {
+		return false, err
+	}
+	if err := patchPersistentVolume(ctx, c, controller, pv, data); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// patchPersistentVolume sends an apply patch for a Persistent Volume as the volrec field manager
+func patchPersistentVolume(ctx context.Context, c client.Client, controller string, pv *corev1.PersistentVolume, data []byte, opts ...client.PatchOption) error {
+	start := time.Now()
+	err := c.Patch(ctx, pv, client.RawPatch(types.ApplyPatchType, data), append(opts, client.FieldOwner(FieldManager))...)
+	metrics.ObserveUpdate(controller, start, err)
+
+	return err
+}
+
+// applyConfiguration builds the apply patch of a Persistent Volume, a nil policy leaves the reclaim policy out
+func applyConfiguration(name, resourceVersion string, labels, annotations map[string]string, policy *corev1.PersistentVolumeReclaimPolicy) ([]byte, error) {
+	metadata := map[string]interface{}{
+		"name":            name,
+		"resourceVersion": resourceVersion,
+		"labels":          labels,
+		"annotations":     annotations,
+	}
+	spec := map[string]interface{}{}
+	if policy != nil {
+		spec["persistentVolumeReclaimPolicy"] = *policy
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"apiVersion": corev1.SchemeGroupVersion.String(),
+		"kind":       "PersistentVolume",
+		"metadata":   metadata,
+		"spec":       spec,
+	})
+}
+
+// claimedValues returns the applied values volrec owns, or adopts from its client-side releases, along with
+// whether any of them is adopted
+func claimedValues(applied map[string]string, owned, legacy map[string]bool) (map[string]string, bool) {
+	claimed := make(map[string]string)
+	adopted := false
+
+	for key, value := range applied {
+		switch {
+		case owned[key]:
+			claimed[key] = value
+		case legacy[key]:
+			claimed[key] = value
+			adopted = true
+		}
+	}
+
+	return claimed, adopted
+}
+
+// isFieldManagerConflict reports whether an apply failed because another field manager owns one of the applied
+// fields with a different value. Retrying won't help until that manager gives the field up, unlike conflicts
+// caused by a stale resourceVersion, which are retried.
+func isFieldManagerConflict(err error) bool {
+	status, ok := err.(apierrors.APIStatus)
+	if !ok || !apierrors.IsConflict(err) || status.Status().Details == nil {
+		return false
+	}
+
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appliedValues returns the values to apply for a label or annotation map, made up of the keys volrec
+// already owns and the keys changed since base, along with whether anything changed
+func appliedValues(owned map[string]bool, base, current map[string]string) (map[string]string, bool) {
+	applied := make(map[string]string)
+	changed := false
+
+	for key, value := range current {
+		if base[key] != value {
+			changed = true
+			applied[key] = value
+		} else if owned[key] {
+			applied[key] = value
+		}
+	}
+
+	for key := range base {
+		if _, ok := current[key]; !ok {
+			changed = true
+		}
+	}
+
+	return applied, changed
+}
+
+// volrecOwnedFields reads the fields owned by the volrec field manager from the managedFields of pv
+func volrecOwnedFields(pv *corev1.PersistentVolume) ownedFields {
+	return managedFields(pv, FieldManager, metav1.ManagedFieldsOperationApply)
+}
+
+// managedFields reads the fields owned by a field manager through an operation from the managedFields of pv
+func managedFields(pv *corev1.PersistentVolume, manager string, operation metav1.ManagedFieldsOperationType) ownedFields {
+	owned := ownedFields{
+		labels:      make(map[string]bool),
+		annotations: make(map[string]bool),
+	}
+
+	for _, entry := range pv.GetManagedFields() {
+		if entry.Manager != manager || entry.Operation != operation || entry.FieldsV1 == nil {
+			continue
+		}
+
+		var fields struct {
+			Metadata struct {
+				Labels      map[string]json.RawMessage `json:"f:labels"`
+				Annotations map[string]json.RawMessage `json:"f:annotations"`
+			} `json:"f:metadata"`
+			Spec map[string]json.RawMessage `json:"f:spec"`
+		}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+
+		for key := range fields.Metadata.Labels {
+			owned.labels[fieldKey(key)] = true
+		}
+		for key := range fields.Metadata.Annotations {
+			owned.annotations[fieldKey(key)] = true
+		}
+		if _, ok := fields.Spec["f:persistentVolumeReclaimPolicy"]; ok {
+			owned.reclaimPolicy = true
+		}
+	}
+
+	return owned
+}
+
+// reclaimPolicyManager returns the field manager, other than volrec, that last set the reclaim policy of pv and
+// when, or an empty name if none of them own it
+func reclaimPolicyManager(pv *corev1.PersistentVolume) (string, time.Time) {
+	var (
+		manager string
+		changed time.Time
+	)
+
+	for _, entry := range pv.GetManagedFields() {
+		if entry.Manager == FieldManager && entry.Operation == metav1.ManagedFieldsOperationApply {
+			continue
+		}
+		if !managedFields(pv, entry.Manager, entry.Operation).reclaimPolicy {
+			continue
+		}
+
+		var entryTime time.Time
+		if entry.Time != nil {
+			entryTime = entry.Time.Time
+		}
+		if manager == "" || entryTime.After(changed) {
+			manager, changed = entry.Manager, entryTime
+		}
+	}
+
+	return manager, changed
+}
+
+// fieldKey strips the "f:" prefix from a managedFields map key
+func fieldKey(key string) string {
+	if len(key) > 2 && key[:2] == "f:" {
+		return key[2:]
+	}
+	return key
+}
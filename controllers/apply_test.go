@@ -0,0 +1,207 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestAppliedValues(t *testing.T) {
+	tests := []struct {
+		name        string
+		owned       map[string]bool
+		base        map[string]string
+		current     map[string]string
+		wantApplied map[string]string
+		wantChanged bool
+	}{
+		{
+			name:        "unchanged and not owned",
+			base:        map[string]string{"app": "db"},
+			current:     map[string]string{"app": "db"},
+			wantApplied: map[string]string{},
+		},
+		{
+			name:        "unchanged and owned",
+			owned:       map[string]bool{"k8s.twr.dev/owner": true},
+			base:        map[string]string{"app": "db", "k8s.twr.dev/owner": "team-a"},
+			current:     map[string]string{"app": "db", "k8s.twr.dev/owner": "team-a"},
+			wantApplied: map[string]string{"k8s.twr.dev/owner": "team-a"},
+		},
+		{
+			name:        "added",
+			base:        map[string]string{"app": "db"},
+			current:     map[string]string{"app": "db", "k8s.twr.dev/owner": "team-a"},
+			wantApplied: map[string]string{"k8s.twr.dev/owner": "team-a"},
+			wantChanged: true,
+		},
+		{
+			name:        "changed",
+			owned:       map[string]bool{"k8s.twr.dev/owner": true},
+			base:        map[string]string{"k8s.twr.dev/owner": "team-a"},
+			current:     map[string]string{"k8s.twr.dev/owner": "team-b"},
+			wantApplied: map[string]string{"k8s.twr.dev/owner": "team-b"},
+			wantChanged: true,
+		},
+		{
+			name:        "removed",
+			owned:       map[string]bool{"k8s.twr.dev/owner": true, "k8s.twr.dev/unclaimed": true},
+			base:        map[string]string{"k8s.twr.dev/owner": "team-a", "k8s.twr.dev/unclaimed": "true"},
+			current:     map[string]string{"k8s.twr.dev/owner": "team-a"},
+			wantApplied: map[string]string{"k8s.twr.dev/owner": "team-a"},
+			wantChanged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied, changed := appliedValues(tt.owned, tt.base, tt.current)
+			if !reflect.DeepEqual(applied, tt.wantApplied) {
+				t.Errorf("appliedValues() applied = %v, want %v", applied, tt.wantApplied)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("appliedValues() changed = %v, want %v", changed, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestVolrecOwnedFields(t *testing.T) {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{
+					Manager:   FieldManager,
+					Operation: metav1.ManagedFieldsOperationApply,
+					FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:k8s.twr.dev/owner":{}},` +
+						`"f:annotations":{"f:storage.k8s.twr.dev/applied-policy":{}}},"f:spec":{"f:persistentVolumeReclaimPolicy":{}}}`)},
+				},
+				{
+					Manager:   legacyFieldManager,
+					Operation: metav1.ManagedFieldsOperationUpdate,
+					FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:k8s.twr.dev/owning-namespace":{}}}}`)},
+				},
+				{
+					Manager:   "external-provisioner",
+					Operation: metav1.ManagedFieldsOperationUpdate,
+					FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:app":{}}},"f:spec":{"f:persistentVolumeReclaimPolicy":{}}}`)},
+				},
+				{
+					Manager:   FieldManager,
+					Operation: metav1.ManagedFieldsOperationApply,
+					FieldsV1:  &metav1.FieldsV1{Raw: []byte(`not json`)},
+				},
+			},
+		},
+	}
+
+	owned := volrecOwnedFields(pv)
+	if want := map[string]bool{"k8s.twr.dev/owner": true}; !reflect.DeepEqual(owned.labels, want) {
+		t.Errorf("volrecOwnedFields() labels = %v, want %v", owned.labels, want)
+	}
+	if want := map[string]bool{"storage.k8s.twr.dev/applied-policy": true}; !reflect.DeepEqual(owned.annotations, want) {
+		t.Errorf("volrecOwnedFields() annotations = %v, want %v", owned.annotations, want)
+	}
+	if !owned.reclaimPolicy {
+		t.Errorf("volrecOwnedFields() reclaimPolicy = false, want true")
+	}
+
+	legacy := managedFields(pv, legacyFieldManager, metav1.ManagedFieldsOperationUpdate)
+	if want := map[string]bool{"k8s.twr.dev/owning-namespace": true}; !reflect.DeepEqual(legacy.labels, want) {
+		t.Errorf("managedFields() labels = %v, want %v", legacy.labels, want)
+	}
+	if legacy.reclaimPolicy {
+		t.Errorf("managedFields() reclaimPolicy = true, want false")
+	}
+
+	if owned := volrecOwnedFields(&corev1.PersistentVolume{}); len(owned.labels) != 0 || len(owned.annotations) != 0 || owned.reclaimPolicy {
+		t.Errorf("volrecOwnedFields() of an unmanaged PV = %+v, want nothing owned", owned)
+	}
+}
+
+func TestClaimedValues(t *testing.T) {
+	applied := map[string]string{"k8s.twr.dev/owner": "team-a", "k8s.twr.dev/owning-namespace": "test1", "app": "db"}
+
+	claimed, adopted := claimedValues(applied, map[string]bool{"k8s.twr.dev/owner": true}, nil)
+	if want := map[string]string{"k8s.twr.dev/owner": "team-a"}; !reflect.DeepEqual(claimed, want) || adopted {
+		t.Errorf("claimedValues() = %v, %v, want %v, false", claimed, adopted, want)
+	}
+
+	claimed, adopted = claimedValues(applied, map[string]bool{"k8s.twr.dev/owner": true}, map[string]bool{"k8s.twr.dev/owning-namespace": true})
+	if want := map[string]string{"k8s.twr.dev/owner": "team-a", "k8s.twr.dev/owning-namespace": "test1"}; !reflect.DeepEqual(claimed, want) || !adopted {
+		t.Errorf("claimedValues() = %v, %v, want %v, true", claimed, adopted, want)
+	}
+}
+
+func TestIsFieldManagerConflict(t *testing.T) {
+	resource := schema.GroupResource{Resource: "persistentvolumes"}
+	fieldConflict := apierrors.NewConflict(resource, "pv-1", nil)
+	fieldConflict.ErrStatus.Details.Causes = []metav1.StatusCause{{Type: metav1.CauseTypeFieldManagerConflict, Field: ".spec.persistentVolumeReclaimPolicy"}}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "field manager conflict", err: fieldConflict, want: true},
+		{name: "stale resourceVersion", err: apierrors.NewConflict(resource, "pv-1", nil)},
+		{name: "not found", err: apierrors.NewNotFound(resource, "pv-1")},
+		{name: "no error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFieldManagerConflict(tt.err); got != tt.want {
+				t.Errorf("isFieldManagerConflict() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReclaimPolicyManager(t *testing.T) {
+	created := metav1.NewTime(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC))
+	edited := metav1.NewTime(created.Add(time.Hour))
+	policy := &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:persistentVolumeReclaimPolicy":{}}}`)}
+
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "external-provisioner", Operation: metav1.ManagedFieldsOperationUpdate, Time: &created, FieldsV1: policy},
+				{Manager: FieldManager, Operation: metav1.ManagedFieldsOperationApply, Time: &edited, FieldsV1: policy},
+				{Manager: "kubectl-edit", Operation: metav1.ManagedFieldsOperationUpdate, Time: &edited, FieldsV1: policy},
+				{Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate, Time: &edited,
+					FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:status":{"f:phase":{}}}`)}},
+			},
+		},
+	}
+
+	manager, changed := reclaimPolicyManager(pv)
+	if manager != "kubectl-edit" || !changed.Equal(edited.Time) {
+		t.Errorf("reclaimPolicyManager() = %q, %v, want %q, %v", manager, changed, "kubectl-edit", edited.Time)
+	}
+
+	if manager, _ := reclaimPolicyManager(&corev1.PersistentVolume{}); manager != "" {
+		t.Errorf("reclaimPolicyManager() of an unmanaged PV = %q, want none", manager)
+	}
+}
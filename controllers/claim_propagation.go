@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"twr.dev/volrec/pkg/config"
+	"twr.dev/volrec/pkg/propagation"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// propagateClaim copies the claim labels and annotations selected by the propagation rules onto its Persistent
+// Volume under the claim prefix, and removes the ones volrec set before that are no longer selected
+func (r *PersistentVolumeClaimReconciler) propagateClaim(ctx context.Context, log logr.Logger, cfg config.ControllerConfig, pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim) error {
+	// The rules are validated with the config
+	labelRules, _ := propagation.ParsePrefixedRules(cfg.ClaimLabels, cfg.ClaimPrefix)
+	annotationRules, _ := propagation.ParsePrefixedRules(cfg.ClaimAnnotations, cfg.ClaimPrefix)
+
+	base := pv.DeepCopy()
+	owned := volrecOwnedFields(base)
+
+	if pv.Labels == nil {
+		pv.Labels = make(map[string]string)
+	}
+	if pv.Annotations == nil {
+		pv.Annotations = make(map[string]string)
+	}
+
+	changes := syncClaimMetadata(cfg, "label", labelRules, pvc.GetLabels(), pv.Labels, owned.labels)
+	changes = append(changes, syncClaimMetadata(cfg, "annotation", annotationRules, pvc.GetAnnotations(), pv.Annotations, owned.annotations)...)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	log.Info("Propagating PVC metadata", "pv", pv.Name, "changes", changes)
+	if _, err := applyPersistentVolume(ctx, r, "ClaimPropagation", pv, base); err != nil {
+		if isFieldManagerConflict(err) {
+			// Retrying won't help until the other field manager gives up the keys
+			log.Info("Propagated PVC metadata on PV is managed by another field manager, skipping", "pv", pv.Name, "reason", err.Error())
+			r.Recorder.Eventf(pvc, corev1.EventTypeWarning, EventReasonFieldConflict, "Metadata %s on PV %s is managed by another field manager and won't be changed: %v", strings.Join(changes, ", "), pv.Name, err)
+			*pv = *base
+			return nil
+		}
+		return fmt.Errorf("could not update PV: %+v", err)
+	}
+
+	r.Recorder.Eventf(pvc, corev1.EventTypeNormal, EventReasonMetadataPropagated, "Propagated %s to PV %s", strings.Join(changes, ", "), pv.Name)
+	r.Recorder.Eventf(pv, corev1.EventTypeNormal, EventReasonMetadataPropagated, "Propagated %s from PVC %s/%s", strings.Join(changes, ", "), pvc.Namespace, pvc.Name)
+
+	return nil
+}
+
+// syncClaimMetadata syncs a label or annotation map of a Persistent Volume with its claim, returning the changes
+// in the kubectl label format, ie. "label pvc.k8s.twr.dev/app=db" or "label pvc.k8s.twr.dev/app-"
+func syncClaimMetadata(cfg config.ControllerConfig, kind string, rules []propagation.Rule, source, target map[string]string, owned map[string]bool) []string {
+	isOwned := func(key string) bool { return owned[key] }
+
+	set, removed := propagation.Sync(rules, source, target, isOwned)
+	// Keys under the prefix are only ever copied from the claim, so the ones left by removed rules are stale too
+	if cfg.ClaimPrefix != "" {
+		removed = append(removed, propagation.Prune(target, propagation.Apply(rules, source), cfg.ClaimPrefix, isOwned)...)
+	}
+
+	var changes []string
+	for _, key := range set {
+		changes = append(changes, fmt.Sprintf("%s %s=%s", kind, key, target[key]))
+	}
+	for _, key := range removed {
+		changes = append(changes, fmt.Sprintf("%s %s-", kind, key))
+	}
+
+	return changes
+}
+
+// claimPropagationChanged reports whether a claim update changes the labels or annotations propagated to its
+// Persistent Volume
+func claimPropagationChanged(cfg config.ControllerConfig, old, new metav1.Object) bool {
+	labelRules, _ := propagation.ParsePrefixedRules(cfg.ClaimLabels, cfg.ClaimPrefix)
+	annotationRules, _ := propagation.ParsePrefixedRules(cfg.ClaimAnnotations, cfg.ClaimPrefix)
+
+	return !reflect.DeepEqual(propagation.Apply(labelRules, old.GetLabels()), propagation.Apply(labelRules, new.GetLabels())) ||
+		!reflect.DeepEqual(propagation.Apply(annotationRules, old.GetAnnotations()), propagation.Apply(annotationRules, new.GetAnnotations()))
+}
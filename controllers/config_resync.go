@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"twr.dev/volrec/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ConfigResyncer requeues every Persistent Volume and Persistent Volume Claim when the controller
+// configuration is reloaded so that new labels, and retention, are applied everywhere
+type ConfigResyncer struct {
+	client.Client
+	Log                         logr.Logger
+	PersistentVolumeEvents      chan<- event.GenericEvent
+	PersistentVolumeClaimEvents chan<- event.GenericEvent
+	// RetentionEvents optionally requeues every Persistent Volume for the retention controller as well
+	RetentionEvents chan<- event.GenericEvent
+}
+
+// Start waits for configuration reloads until the stop channel is closed
+func (r *ConfigResyncer) Start(stop <-chan struct{}) error {
+	reloads := config.Subscribe()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case cfg := <-reloads:
+			r.Log.Info("Config reloaded, resyncing PV's and PVC's", "config", cfg)
+			if err := r.resync(stop); err != nil {
+				r.Log.Error(err, "unable to resync after config reload")
+			}
+		}
+	}
+}
+
+// resync sends a generic event for every Persistent Volume and Persistent Volume Claim in the cache
+func (r *ConfigResyncer) resync(stop <-chan struct{}) error {
+	ctx := context.Background()
+
+	var (
+		pvs  corev1.PersistentVolumeList
+		pvcs corev1.PersistentVolumeClaimList
+	)
+
+	if err := r.List(ctx, &pvs); err != nil {
+		return err
+	}
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		for _, events := range []chan<- event.GenericEvent{r.PersistentVolumeEvents, r.RetentionEvents} {
+			if events == nil {
+				continue
+			}
+			select {
+			case events <- event.GenericEvent{Meta: pv, Object: pv}:
+			case <-stop:
+				return nil
+			}
+		}
+	}
+
+	if err := r.List(ctx, &pvcs); err != nil {
+		return err
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		select {
+		case r.PersistentVolumeClaimEvents <- event.GenericEvent{Meta: pvc, Object: pvc}:
+		case <-stop:
+			return nil
+		}
+	}
+
+	r.Log.Info("Resync queued", "pvs", len(pvs.Items), "pvcs", len(pvcs.Items))
+
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestConfigResyncerResync(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-1"}},
+		&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-2"}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "test1"}},
+	)
+
+	received := func(events chan event.GenericEvent) []string {
+		var names []string
+		for len(events) > 0 {
+			names = append(names, (<-events).Meta.GetName())
+		}
+		return names
+	}
+
+	t.Run("every controller", func(t *testing.T) {
+		pvEvents, pvcEvents, retentionEvents := make(chan event.GenericEvent, 10), make(chan event.GenericEvent, 10), make(chan event.GenericEvent, 10)
+		r := &ConfigResyncer{Client: c, Log: zap.New(zap.UseDevMode(true)),
+			PersistentVolumeEvents: pvEvents, PersistentVolumeClaimEvents: pvcEvents, RetentionEvents: retentionEvents}
+
+		if err := r.resync(make(chan struct{})); err != nil {
+			t.Fatal(err)
+		}
+		if got := received(pvEvents); len(got) != 2 {
+			t.Errorf("expected 2 PV events, got %v", got)
+		}
+		if got := received(retentionEvents); len(got) != 2 {
+			t.Errorf("expected 2 retention events, got %v", got)
+		}
+		if got := received(pvcEvents); len(got) != 1 {
+			t.Errorf("expected 1 PVC event, got %v", got)
+		}
+	})
+
+	t.Run("without retention", func(t *testing.T) {
+		pvEvents, pvcEvents := make(chan event.GenericEvent, 10), make(chan event.GenericEvent, 10)
+		r := &ConfigResyncer{Client: c, Log: zap.New(zap.UseDevMode(true)),
+			PersistentVolumeEvents: pvEvents, PersistentVolumeClaimEvents: pvcEvents}
+
+		if err := r.resync(make(chan struct{})); err != nil {
+			t.Fatal(err)
+		}
+		if got := received(pvEvents); len(got) != 2 {
+			t.Errorf("expected 2 PV events, got %v", got)
+		}
+	})
+}
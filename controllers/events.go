@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+// Event reasons recorded on Persistent Volume Claims and Persistent Volumes
+const (
+	// EventReasonReclaimPolicyApplied is recorded when the reclaim policy on a PV is changed to match its PVC
+	EventReasonReclaimPolicyApplied = "ReclaimPolicyApplied"
+	// EventReasonReclaimPolicyUnchanged is recorded when the PVC label is changed to the reclaim policy its PV already has
+	EventReasonReclaimPolicyUnchanged = "ReclaimPolicyUnchanged"
+	// EventReasonInvalidReclaimPolicy is recorded when a PVC requests an unsupported reclaim policy
+	EventReasonInvalidReclaimPolicy = "InvalidReclaimPolicy"
+	// EventReasonPendingBinding is recorded when a PVC has a reclaim policy label but isn't bound to a PV yet
+	EventReasonPendingBinding = "PendingBinding"
+	// EventReasonLabelsApplied is recorded when owner information is copied onto a PV
+	EventReasonLabelsApplied = "LabelsApplied"
+	// EventReasonLabelsRemoved is recorded when labels copied onto a PV are removed because their source is gone
+	EventReasonLabelsRemoved = "LabelsRemoved"
+	// EventReasonMetadataPropagated is recorded when Namespace labels or annotations are mirrored onto a PV
+	EventReasonMetadataPropagated = "MetadataPropagated"
+	// EventReasonInvalidRule is recorded on a VolumeReclaimRule that can't be evaluated and is skipped
+	EventReasonInvalidRule = "InvalidRule"
+	// EventReasonInvalidRestriction is recorded on a VolumeReclaimRestriction that can't be evaluated and denies destructive policies
+	EventReasonInvalidRestriction = "InvalidRestriction"
+	// EventReasonReclaimPolicyLocked is recorded when a PV is administratively locked and won't be modified
+	EventReasonReclaimPolicyLocked = "ReclaimPolicyLocked"
+	// EventReasonReclaimPolicyNotAllowed is recorded when a VolumeReclaimRestriction doesn't allow the requested policy
+	EventReasonReclaimPolicyNotAllowed = "ReclaimPolicyNotAllowed"
+	// EventReasonReclaimPolicyProtected is recorded when a destructive reclaim policy is blocked for a protected volume
+	EventReasonReclaimPolicyProtected = "ReclaimPolicyProtected"
+	// EventReasonUpdateFailed is recorded when volrec is unable to update a PV
+	EventReasonUpdateFailed = "UpdateFailed"
+	// EventReasonFieldConflict is recorded when a field volrec applies on a PV is owned by another field manager
+	EventReasonFieldConflict = "FieldConflict"
+	// EventReasonInvalidRetention is recorded when a PVC, Namespace, or PV requests an unsupported retention
+	EventReasonInvalidRetention = "InvalidRetention"
+	// EventReasonRetentionExpired is recorded when a released PV has been retained for as long as requested
+	EventReasonRetentionExpired = "RetentionExpired"
+	// EventReasonVolumeRebound is recorded when a Released PV is pre-bound to a new PVC on request
+	EventReasonVolumeRebound = "VolumeRebound"
+	// EventReasonRebindFailed is recorded when a Released PV can't be bound to the PVC requesting it
+	EventReasonRebindFailed = "RebindFailed"
+	// EventReasonSnapshotCreated is recorded when a VolumeSnapshot is taken before a PV is switched to Delete
+	EventReasonSnapshotCreated = "SnapshotCreated"
+	// EventReasonSnapshotFailed is recorded when a VolumeSnapshot can't be taken before a PV is switched to Delete
+	EventReasonSnapshotFailed = "SnapshotFailed"
+)
@@ -0,0 +1,277 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"twr.dev/volrec/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ClaimRefNamespaceField indexes Persistent Volumes by the Namespace of the claim they are bound to
+	ClaimRefNamespaceField = "spec.claimRef.namespace"
+	// ClaimRefNameField indexes Persistent Volumes by the claim they are bound to. The cache only supports
+	// matching a single field, so the value is the namespaced name of the claim, ie. "namespace/name"
+	ClaimRefNameField = "spec.claimRef.name"
+	// StorageClassNameField indexes Persistent Volumes by their StorageClass
+	StorageClassNameField = "spec.storageClassName"
+	// PodClaimNameField indexes Pods by the claims they mount
+	PodClaimNameField = "spec.volumes.persistentVolumeClaim.claimName"
+)
+
+// IndexPersistentVolumes registers the claimRef and StorageClass field indexes for Persistent Volumes, it
+// must be called before the manager is started
+func IndexPersistentVolumes(indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(&corev1.PersistentVolume{}, ClaimRefNamespaceField, func(obj runtime.Object) []string {
+		claimRef := obj.(*corev1.PersistentVolume).Spec.ClaimRef
+		if claimRef == nil || claimRef.Namespace == "" {
+			return nil
+		}
+		return []string{claimRef.Namespace}
+	}); err != nil {
+		return err
+	}
+
+	if err := indexer.IndexField(&corev1.PersistentVolume{}, ClaimRefNameField, func(obj runtime.Object) []string {
+		claimRef := obj.(*corev1.PersistentVolume).Spec.ClaimRef
+		if claimRef == nil || claimRef.Name == "" {
+			return nil
+		}
+		return []string{claimKey(claimRef.Namespace, claimRef.Name)}
+	}); err != nil {
+		return err
+	}
+
+	return indexer.IndexField(&corev1.PersistentVolume{}, StorageClassNameField, func(obj runtime.Object) []string {
+		storageClassName := obj.(*corev1.PersistentVolume).Spec.StorageClassName
+		if storageClassName == "" {
+			return nil
+		}
+		return []string{storageClassName}
+	})
+}
+
+// IndexPods registers the claim name field index for Pods, it must be called before the manager is started
+func IndexPods(indexer client.FieldIndexer) error {
+	return indexer.IndexField(&corev1.Pod{}, PodClaimNameField, func(obj runtime.Object) []string {
+		var claims []string
+		for _, volume := range obj.(*corev1.Pod).Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				claims = append(claims, volume.PersistentVolumeClaim.ClaimName)
+			}
+		}
+		return claims
+	})
+}
+
+// claimKey returns the ClaimRefNameField index value for a claim
+func claimKey(namespace, name string) string {
+	return types.NamespacedName{Namespace: namespace, Name: name}.String()
+}
+
+// boundVolumeName returns the name of the Persistent Volume a claim is bound to. Falls back to the
+// ClaimRefNameField index while the PV controller is still binding the claim and hasn't set its volumeName.
+func boundVolumeName(ctx context.Context, c client.Reader, pvc *corev1.PersistentVolumeClaim) (string, error) {
+	if pvc.Spec.VolumeName != "" {
+		return pvc.Spec.VolumeName, nil
+	}
+
+	var pvs corev1.PersistentVolumeList
+
+	if err := c.List(ctx, &pvs, client.MatchingFields{ClaimRefNameField: claimKey(pvc.Namespace, pvc.Name)}); err != nil {
+		return "", err
+	}
+
+	for _, pv := range pvs.Items {
+		if pv.Spec.ClaimRef.UID == pvc.UID && pv.Status.Phase == corev1.VolumeBound {
+			return pv.Name, nil
+		}
+	}
+
+	return "", nil
+}
+
+// volumesForClaim maps a Persistent Volume Claim to the PV it is bound to
+func volumesForClaim(c client.Reader, log logr.Logger) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		pvc, ok := obj.Object.(*corev1.PersistentVolumeClaim)
+		if !ok {
+			return nil
+		}
+
+		volumeName, err := boundVolumeName(context.Background(), c, pvc)
+		if err != nil {
+			log.Error(err, "unable to find PV for PVC", "pvc", types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name})
+			return nil
+		}
+		if volumeName == "" {
+			return nil
+		}
+
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: volumeName}}}
+	}
+}
+
+// volumesForNamespace maps a Namespace to every PV bound to a claim in it
+func volumesForNamespace(c client.Reader, log logr.Logger) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		var pvs corev1.PersistentVolumeList
+
+		if err := c.List(context.Background(), &pvs, client.MatchingFields{ClaimRefNamespaceField: obj.Meta.GetName()}); err != nil {
+			log.Error(err, "unable to list PV's for Namespace", "namespace", obj.Meta.GetName())
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(pvs.Items))
+		for _, pv := range pvs.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: pv.Name}})
+		}
+
+		return requests
+	}
+}
+
+// volumesForStorageClass maps a StorageClass to every PV of that class
+func volumesForStorageClass(c client.Reader, log logr.Logger) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		var pvs corev1.PersistentVolumeList
+
+		if err := c.List(context.Background(), &pvs, client.MatchingFields{StorageClassNameField: obj.Meta.GetName()}); err != nil {
+			log.Error(err, "unable to list PV's for StorageClass", "storageClass", obj.Meta.GetName())
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(pvs.Items))
+		for _, pv := range pvs.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: pv.Name}})
+		}
+
+		return requests
+	}
+}
+
+// claimsForStorageClass maps a StorageClass to the claim of every PV of that class which is bound to one
+func claimsForStorageClass(c client.Reader, log logr.Logger) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		var pvs corev1.PersistentVolumeList
+
+		if err := c.List(context.Background(), &pvs, client.MatchingFields{StorageClassNameField: obj.Meta.GetName()}); err != nil {
+			log.Error(err, "unable to list PV's for StorageClass", "storageClass", obj.Meta.GetName())
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for i := range pvs.Items {
+			requests = append(requests, claimForVolume(handler.MapObject{Meta: &pvs.Items[i], Object: &pvs.Items[i]})...)
+		}
+
+		return requests
+	}
+}
+
+// releasedVolumes maps an object to every released PV
+func releasedVolumes(c client.Reader, log logr.Logger) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		var pvs corev1.PersistentVolumeList
+
+		if err := c.List(context.Background(), &pvs); err != nil {
+			log.Error(err, "unable to list PV's", "name", obj.Meta.GetName())
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, pv := range pvs.Items {
+			if pv.Status.Phase == corev1.VolumeReleased {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: pv.Name}})
+			}
+		}
+
+		return requests
+	}
+}
+
+// claimsForNamespace maps a Namespace to every claim in it without its own reclaim policy label, so the
+// Namespace default reclaim policy is (re)applied
+func claimsForNamespace(c client.Reader, log logr.Logger) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		var pvcs corev1.PersistentVolumeClaimList
+
+		if err := c.List(context.Background(), &pvcs, client.InNamespace(obj.Meta.GetName())); err != nil {
+			log.Error(err, "unable to list PVC's for Namespace", "namespace", obj.Meta.GetName())
+			return nil
+		}
+
+		reclaimPolicyLabel := config.Get().ReclaimPolicyLabel
+		requests := make([]reconcile.Request, 0, len(pvcs.Items))
+		for _, pvc := range pvcs.Items {
+			if _, ok := pvc.GetLabels()[reclaimPolicyLabel]; ok {
+				continue
+			}
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}})
+		}
+
+		log.Info("Requeued PVC's for Namespace default reclaim policy", "namespace", obj.Meta.GetName(), "pvcs", len(requests))
+
+		return requests
+	}
+}
+
+// claimsForRules maps a VolumeReclaimRule to every claim bound to a PV. Any rule can change the winning rule
+// for any claim, so there's nothing narrower to requeue.
+func claimsForRules(c client.Reader, log logr.Logger) handler.ToRequestsFunc {
+	return boundClaims(c, log, "VolumeReclaimRule")
+}
+
+// claimsForRestrictions maps a VolumeReclaimRestriction to every claim bound to a PV. The claims that were
+// matched before the restriction changed are just as affected as the ones matched now, and only the new
+// version is known here, so every claim is rechecked.
+func claimsForRestrictions(c client.Reader, log logr.Logger) handler.ToRequestsFunc {
+	return boundClaims(c, log, "VolumeReclaimRestriction")
+}
+
+// boundClaims maps an object of the given kind to every claim bound to a PV
+func boundClaims(c client.Reader, log logr.Logger, kind string) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		var pvcs corev1.PersistentVolumeClaimList
+
+		if err := c.List(context.Background(), &pvcs); err != nil {
+			log.Error(err, "unable to list PVC's for "+kind, "name", obj.Meta.GetName())
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(pvcs.Items))
+		for _, pvc := range pvcs.Items {
+			if pvc.Spec.VolumeName == "" {
+				continue
+			}
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}})
+		}
+
+		log.Info("Requeued PVC's for "+kind+" change", "name", obj.Meta.GetName(), "pvcs", len(requests))
+
+		return requests
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"twr.dev/volrec/pkg/reclaim"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+
+// volumeLocked reports whether a Persistent Volume has been administratively locked, either directly or
+// through its StorageClass, along with a description of where the lock came from
+func volumeLocked(ctx context.Context, c client.Reader, pv *corev1.PersistentVolume) (bool, string, error) {
+	if reclaim.IsLocked(pv) {
+		return true, fmt.Sprintf("annotation %s on PV %s", reclaim.LockedAnnotation, pv.Name), nil
+	}
+
+	if pv.Spec.StorageClassName == "" {
+		return false, "", nil
+	}
+
+	var sc storagev1.StorageClass
+
+	if err := c.Get(ctx, client.ObjectKey{Name: pv.Spec.StorageClassName}, &sc); err != nil {
+		return false, "", client.IgnoreNotFound(err)
+	}
+
+	if reclaim.IsLocked(&sc) {
+		return true, fmt.Sprintf("annotation %s on StorageClass %s", reclaim.LockedAnnotation, sc.Name), nil
+	}
+
+	return false, "", nil
+}
+
+// lockChanged filters updates to the objects carrying the locked annotation, so whatever was skipped while
+// they were locked is reconciled once they are unlocked
+var lockChanged = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		return false
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return reclaim.IsLocked(e.MetaOld) != reclaim.IsLocked(e.MetaNew)
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return false
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
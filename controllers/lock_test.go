@@ -0,0 +1,58 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"twr.dev/volrec/pkg/reclaim"
+
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+func TestLockChanged(t *testing.T) {
+	storageClass := func(annotations map[string]string) *storagev1.StorageClass {
+		return &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard", Annotations: annotations}}
+	}
+
+	tests := []struct {
+		name string
+		old  map[string]string
+		new  map[string]string
+		want bool
+	}{
+		{"no annotations", nil, nil, false},
+		{"locked", nil, map[string]string{reclaim.LockedAnnotation: "true"}, true},
+		{"unlocked", map[string]string{reclaim.LockedAnnotation: "true"}, nil, true},
+		{"unlocked by value", map[string]string{reclaim.LockedAnnotation: "true"}, map[string]string{reclaim.LockedAnnotation: "false"}, true},
+		{"still locked", map[string]string{reclaim.LockedAnnotation: "true"}, map[string]string{reclaim.LockedAnnotation: "1"}, false},
+		{"other annotation", nil, map[string]string{"example.com/owner": "team-a"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldSC, newSC := storageClass(tt.old), storageClass(tt.new)
+			e := event.UpdateEvent{MetaOld: oldSC, ObjectOld: oldSC, MetaNew: newSC, ObjectNew: newSC}
+
+			if got := lockChanged.Update(e); got != tt.want {
+				t.Errorf("lockChanged.Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -19,53 +19,72 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"twr.dev/volrec/pkg/config"
+	"twr.dev/volrec/pkg/propagation"
+	"twr.dev/volrec/pkg/reclaim"
 
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 )
 
 // PersistentVolumeReconciler reconciles a PersistentVolume object
 type PersistentVolumeReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// ResyncEvents optionally requeues objects outside of the normal watch, ie. on config reload
+	ResyncEvents <-chan event.GenericEvent
 }
 
 // VolumeMap maps a Kubernetes Persistent Volume, the associated Volume Claim, and the
 // Namespace that it's assocaited with
 type VolumeMap struct {
-	pvName           string
-	pvClaimKind      string
-	pvClaimName      string
 	pvClaimNamespace string
 	nsOwner          string
 }
 
 // buildNamespaceMap Builds mapping of PV -> PVC -> Namespace and associated owner
-func buildNamespaceMap(ctx context.Context, r *PersistentVolumeReconciler, log logr.Logger, pv corev1.PersistentVolume, ownerLabel string) string {
-	var (
-		ns corev1.Namespace
-	)
+func buildNamespaceMap(ctx context.Context, r *PersistentVolumeReconciler, log logr.Logger, claimRef *corev1.ObjectReference, ownerLabel string) string {
+	ns := claimNamespace(ctx, r, log, claimRef)
+	if ns == nil {
+		return ""
+	}
 
-	nsOwner := ""
+	return ns.GetLabels()[ownerLabel]
+}
 
-	if err := r.Get(ctx, client.ObjectKey{Name: pv.Spec.ClaimRef.Namespace}, &ns); err != nil {
-		log.Error(err, "unable to fetch namespace")
-		return ""
+// claimNamespace fetches the Namespace of the claim a Persistent Volume is bound to, nil if it can't be fetched
+func claimNamespace(ctx context.Context, r *PersistentVolumeReconciler, log logr.Logger, claimRef *corev1.ObjectReference) *corev1.Namespace {
+	var ns corev1.Namespace
+
+	if claimRef == nil || claimRef.Namespace == "" {
+		return nil
 	}
 
-	nsOwner = ns.GetLabels()[config.VolrecConfig.OwnerLabel]
-	return nsOwner
+	if err := r.Get(ctx, client.ObjectKey{Name: claimRef.Namespace}, &ns); err != nil {
+		log.Error(err, "unable to fetch namespace")
+		return nil
+	}
 
+	return &ns
 }
 
 // +kubebuilder:rbac:groups=core,resources=persistentvolumes,verbs=get;list;watch;update;patch
@@ -74,33 +93,58 @@ func buildNamespaceMap(ctx context.Context, r *PersistentVolumeReconciler, log l
 func (r *PersistentVolumeReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 	log := r.Log.WithValues("pv", req.NamespacedName)
+	cfg := config.Get()
 
 	var (
-		pv  corev1.PersistentVolume
-		pvc corev1.PersistentVolumeClaim
-		// Commenting out until i solve for no shell in controller image
-		//reclaimPolicyLabel string = viper.GetString("storage.reclaim.label")
-		//ownerLabel         string = viper.GetString("owner.label")
-		//ownerSet           bool   = viper.GetBool("owner.set-owner")
-		pvMap VolumeMap
+		pv      corev1.PersistentVolume
+		pvc     corev1.PersistentVolumeClaim
+		pvMap   VolumeMap
+		applied []string
 	)
 
 	if err := r.Get(ctx, req.NamespacedName, &pv); err != nil {
 		log.Error(err, "unable to fetch PV")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	base := pv.DeepCopy()
+
+	locked, lockSource, err := volumeLocked(ctx, r, &pv)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if locked {
+		log.Info("PV is administratively locked, skipping", "lock-source", lockSource)
+		return ctrl.Result{}, nil
+	}
+
+	recordExternalPolicyChange(log, &pv)
+
+	// Statically provisioned PV's are Available without a claimRef, and Released/Failed PV's still
+	// reference a claim that no longer exists. Both are skipped until they are bound (again).
+	if pv.Spec.ClaimRef == nil {
+		log.Info("PV isn't claimed, skipping", "phase", pv.Status.Phase)
+		return r.reconcileUnclaimed(ctx, log, cfg, &pv, base)
+	}
 
-	// Need to figure out how to filter this on delete events
 	if err := r.Get(ctx, client.ObjectKey{Name: pv.Spec.ClaimRef.Name, Namespace: pv.Spec.ClaimRef.Namespace}, &pvc); err != nil {
-		//log.Error(err, "unable to fetch PVC", "namespace", pvc.Namespace, "pvc", pvc.Name)
 		if apierrors.IsNotFound(err) {
-			return ctrl.Result{}, nil
+			log.Info("Claim for PV doesn't exist, skipping", "phase", pv.Status.Phase, "namespace", pv.Spec.ClaimRef.Namespace, "pvc", pv.Spec.ClaimRef.Name)
+			return r.reconcileUnclaimed(ctx, log, cfg, &pv, base)
 		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	if pv.Spec.ClaimRef.UID != "" && pv.Spec.ClaimRef.UID != pvc.UID {
+		log.Info("Claim for PV was recreated, skipping", "phase", pv.Status.Phase, "namespace", pvc.Namespace, "pvc", pvc.Name)
+		return r.reconcileUnclaimed(ctx, log, cfg, &pv, base)
+	}
+
+	if _, ok := pv.GetLabels()[cfg.UnclaimedLabel]; ok && cfg.UnclaimedLabel != "" {
+		log.Info("Removing unclaimed label", "unclaimed-label", cfg.UnclaimedLabel)
+		delete(pv.Labels, cfg.UnclaimedLabel)
+	}
 
 	pvcLabels := pvc.GetLabels()
-	reclaimPolicyFromPVCLabel := pvcLabels[config.VolrecConfig.ReclaimPolicyLabel]
+	reclaimPolicyFromPVCLabel := pvcLabels[cfg.ReclaimPolicyLabel]
 	log.Info("Reconciling PV", "policy-from-label", reclaimPolicyFromPVCLabel)
 
 	/* Don't think we need this section....only valid for direct edits to PV which should probably be ignored to allow for admin override
@@ -114,66 +158,370 @@ func (r *PersistentVolumeReconciler) Reconcile(req ctrl.Request) (ctrl.Result, e
 	}
 	*/
 
+	ns := claimNamespace(ctx, r, log, pv.Spec.ClaimRef)
+	if ns != nil {
+		pvMap.nsOwner = ns.GetLabels()[cfg.OwnerLabel]
+	}
+
+	// Keep the provenance current while bound, it's frozen once the PV is released
+	reclaim.SetProvenance(&pv, reclaim.Provenance{
+		Namespace:    pvc.Namespace,
+		Claim:        pvc.Name,
+		Owner:        pvMap.nsOwner,
+		StorageClass: pv.Spec.StorageClassName,
+	})
+
 	// if owner label is enabled and does not already exist, set it
-	if config.VolrecConfig.OwnerSet == true || config.VolrecConfig.NsSet == true {
+	if cfg.OwnerSet == true || cfg.NsSet == true {
 
-		pvMap.nsOwner = buildNamespaceMap(ctx, r, log, pv, config.VolrecConfig.OwnerLabel)
-		pvMap.pvName = pv.Name
-		pvMap.pvClaimKind = pv.Spec.ClaimRef.Kind
-		pvMap.pvClaimName = pv.Spec.ClaimRef.Name
 		pvMap.pvClaimNamespace = pv.Spec.ClaimRef.Namespace
 
 		// Set Owner Label
-		if config.VolrecConfig.OwnerSet == true {
-			if pvMap.nsOwner == pv.GetLabels()[config.VolrecConfig.OwnerLabel] {
-				log.Info("Owner label already set on PV", "owner-label", config.VolrecConfig.OwnerLabel, "owner", pvMap.nsOwner)
+		if cfg.OwnerSet == true {
+			if pvMap.nsOwner == pv.GetLabels()[cfg.OwnerLabel] {
+				log.Info("Owner label already set on PV", "owner-label", cfg.OwnerLabel, "owner", pvMap.nsOwner)
 			} else if pvMap.nsOwner == "" {
-				log.Info("Owner label isn't set or is blank", "owner-label", config.VolrecConfig.OwnerLabel)
+				log.Info("Owner label isn't set or is blank", "owner-label", cfg.OwnerLabel)
 			} else {
-				log.Info("Setting Owner label", "owner-label", config.VolrecConfig.OwnerLabel, "owner", pvMap.nsOwner)
+				log.Info("Setting Owner label", "owner-label", cfg.OwnerLabel, "owner", pvMap.nsOwner)
 				if len(pv.Labels) == 0 {
 					pv.Labels = make(map[string]string)
 				}
-				pv.Labels[config.VolrecConfig.OwnerLabel] = pvMap.nsOwner
+				pv.Labels[cfg.OwnerLabel] = pvMap.nsOwner
+				applied = append(applied, fmt.Sprintf("%s=%s", cfg.OwnerLabel, pvMap.nsOwner))
 			}
 		}
 
 		// Set Owning Namespace Label
-		if config.VolrecConfig.NsSet == true {
-			if pvMap.pvClaimNamespace == pv.GetLabels()[config.VolrecConfig.NsLabel] {
-				log.Info("Owning Namespace label already set on PV", "ns-label", config.VolrecConfig.NsLabel, "namespace", pvMap.pvClaimNamespace)
+		if cfg.NsSet == true {
+			if pvMap.pvClaimNamespace == pv.GetLabels()[cfg.NsLabel] {
+				log.Info("Owning Namespace label already set on PV", "ns-label", cfg.NsLabel, "namespace", pvMap.pvClaimNamespace)
 			} else if pvMap.pvClaimNamespace == "" {
 				log.Info("Namespace not set in claimRef on PV")
 			} else {
-				log.Info("Setting owning Namespace label", "ns-label", config.VolrecConfig.NsLabel, "namespace", pvMap.pvClaimNamespace)
+				log.Info("Setting owning Namespace label", "ns-label", cfg.NsLabel, "namespace", pvMap.pvClaimNamespace)
 				if len(pv.Labels) == 0 {
 					pv.Labels = make(map[string]string)
 				}
-				pv.Labels[config.VolrecConfig.NsLabel] = pvMap.pvClaimNamespace
+				pv.Labels[cfg.NsLabel] = pvMap.pvClaimNamespace
+				applied = append(applied, fmt.Sprintf("%s=%s", cfg.NsLabel, pvMap.pvClaimNamespace))
 			}
 		}
 	}
 
-	// Update Persistent Volume
-	err := r.Update(context.TODO(), &pv)
-	if err != nil {
+	// Mirror the selected Namespace labels and annotations, unless the Namespace couldn't be fetched, in which
+	// case the labels set before are kept as is
+	var propagated, removed []string
+	if ns != nil {
+		propagated = propagateNamespace(log, cfg, ns, &pv, base)
+
+		// Remove what was copied before but isn't anymore, ie. the owner label once it's removed from the Namespace
+		keepLabels, keepAnnotations := namespaceMetadataKeys(cfg, ns, pvMap.nsOwner)
+		removed = pruneNamespaceMetadata(log, cfg, &pv, base, keepLabels, keepAnnotations)
+	}
 
+	// Patch Persistent Volume
+	if _, err := applyPersistentVolume(ctx, r, "PersistentVolume", &pv, base); err != nil {
+		if isFieldManagerConflict(err) {
+			// Retrying won't help until the other field manager gives up the labels
+			log.Info("Labels on PV are managed by another field manager, skipping", "labels", applied, "propagated", propagated, "reason", err.Error())
+			r.Recorder.Eventf(&pv, corev1.EventTypeWarning, EventReasonFieldConflict, "Labels %s are managed by another field manager and won't be changed: %v", strings.Join(append(applied, propagated...), ", "), err)
+			return ctrl.Result{}, nil
+		}
 		if apierrors.IsConflict(err) {
-			return reconcile.Result{Requeue: true}, nil
+			// The PV changed since it was read, retry with the latest version
+			return ctrl.Result{Requeue: true}, nil
+		}
+		r.Recorder.Eventf(&pv, corev1.EventTypeWarning, EventReasonUpdateFailed, "Unable to update PV: %v", err)
+		return reconcile.Result{}, fmt.Errorf("could not update PV: %+v", err)
+	}
+
+	if len(applied) > 0 {
+		r.Recorder.Eventf(&pv, corev1.EventTypeNormal, EventReasonLabelsApplied, "Set labels %s from Namespace %s", strings.Join(applied, ", "), pvMap.pvClaimNamespace)
+		r.Recorder.Eventf(&pvc, corev1.EventTypeNormal, EventReasonLabelsApplied, "Set labels %s on PV %s", strings.Join(applied, ", "), pv.Name)
+	}
+	if len(propagated) > 0 {
+		r.Recorder.Eventf(&pv, corev1.EventTypeNormal, EventReasonMetadataPropagated, "Propagated %s from Namespace %s", strings.Join(propagated, ", "), pvc.Namespace)
+	}
+	if len(removed) > 0 {
+		r.Recorder.Eventf(&pv, corev1.EventTypeNormal, EventReasonLabelsRemoved, "Removed %s no longer set on Namespace %s", strings.Join(removed, ", "), pvc.Namespace)
+		r.Recorder.Eventf(&pvc, corev1.EventTypeNormal, EventReasonLabelsRemoved, "Removed %s no longer set on Namespace %s from PV %s", strings.Join(removed, ", "), pvc.Namespace, pv.Name)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// propagateNamespace mirrors the Namespace labels and annotations selected by the propagation rules onto a
+// Persistent Volume, and removes the ones volrec set before that are no longer on the Namespace. The changes
+// are returned in the kubectl label format, ie. "label cost-center=1234" or "annotation cost-center-".
+func propagateNamespace(log logr.Logger, cfg config.ControllerConfig, ns *corev1.Namespace, pv, base *corev1.PersistentVolume) []string {
+	// The rules are validated with the config
+	labelRules, _ := propagation.ParseRules(cfg.NamespaceLabels)
+	annotationRules, _ := propagation.ParseRules(cfg.NamespaceAnnotations)
+	owned := volrecOwnedFields(base)
+
+	if pv.Labels == nil {
+		pv.Labels = make(map[string]string)
+	}
+	if pv.Annotations == nil {
+		pv.Annotations = make(map[string]string)
+	}
+
+	var changes []string
+
+	set, removed := propagation.Sync(labelRules, ns.GetLabels(), pv.Labels, func(key string) bool { return owned.labels[key] })
+	for _, key := range set {
+		changes = append(changes, fmt.Sprintf("label %s=%s", key, pv.Labels[key]))
+	}
+	for _, key := range removed {
+		changes = append(changes, fmt.Sprintf("label %s-", key))
+	}
+
+	set, removed = propagation.Sync(annotationRules, ns.GetAnnotations(), pv.Annotations, func(key string) bool { return owned.annotations[key] })
+	for _, key := range set {
+		changes = append(changes, fmt.Sprintf("annotation %s=%s", key, pv.Annotations[key]))
+	}
+	for _, key := range removed {
+		changes = append(changes, fmt.Sprintf("annotation %s-", key))
+	}
+
+	if len(changes) > 0 {
+		log.Info("Propagating Namespace metadata", "namespace", ns.Name, "changes", changes)
+	}
+
+	return changes
+}
+
+// namespaceMetadataKeys returns the keys of the labels and annotations copied onto a Persistent Volume from the
+// Namespace of its claim with the current config
+func namespaceMetadataKeys(cfg config.ControllerConfig, ns *corev1.Namespace, nsOwner string) (labels, annotations map[string]bool) {
+	labelRules, _ := propagation.ParseRules(cfg.NamespaceLabels)
+	annotationRules, _ := propagation.ParseRules(cfg.NamespaceAnnotations)
+
+	labels = make(map[string]bool)
+	annotations = make(map[string]bool)
+
+	for key := range propagation.Apply(labelRules, ns.GetLabels()) {
+		labels[key] = true
+	}
+	for key := range propagation.Apply(annotationRules, ns.GetAnnotations()) {
+		annotations[key] = true
+	}
+	if cfg.OwnerSet && nsOwner != "" {
+		labels[cfg.OwnerLabel] = true
+	}
+	if cfg.NsSet {
+		labels[cfg.NsLabel] = true
+	}
+
+	return labels, annotations
+}
+
+// releasedMetadataKeys returns the keys of the labels and annotations copied from the Namespace that are kept on
+// a Persistent Volume released from its claim. The Namespace may already be gone, so the keys copied while the PV
+// was bound, and the owner and owning Namespace labels, are kept unless copying them has been disabled since.
+func releasedMetadataKeys(cfg config.ControllerConfig, pv *corev1.PersistentVolume) (labels, annotations map[string]bool) {
+	labelRules, _ := propagation.ParseRules(cfg.NamespaceLabels)
+	annotationRules, _ := propagation.ParseRules(cfg.NamespaceAnnotations)
+
+	labels = make(map[string]bool)
+	annotations = make(map[string]bool)
+
+	for _, key := range reclaim.GetTrackedKeys(pv, reclaim.NamespaceLabelsAnnotation) {
+		labels[key] = produced(labelRules, key)
+	}
+	if cfg.OwnerSet {
+		labels[cfg.OwnerLabel] = true
+	}
+	if cfg.NsSet {
+		labels[cfg.NsLabel] = true
+	}
+	for _, key := range reclaim.GetTrackedKeys(pv, reclaim.NamespaceAnnotationsAnnotation) {
+		annotations[key] = produced(annotationRules, key)
+	}
+
+	return labels, annotations
+}
+
+// produced reports whether any of the rules can produce a key
+func produced(rules []propagation.Rule, key string) bool {
+	for _, rule := range rules {
+		if rule.Produces(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneNamespaceMetadata removes the labels and annotations volrec copied from the Namespace before that aren't
+// kept anymore, and records the kept keys on the Persistent Volume for the next reconcile. Only keys volrec still
+// owns are removed, and the configured owner and owning Namespace labels are considered too, for PV's labelled
+// before the keys were recorded. The removed keys are returned in the kubectl label format, ie. "label owner-".
+func pruneNamespaceMetadata(log logr.Logger, cfg config.ControllerConfig, pv, base *corev1.PersistentVolume, keepLabels, keepAnnotations map[string]bool) []string {
+	owned := volrecOwnedFields(base)
+	var removed []string
+
+	for _, key := range append(reclaim.GetTrackedKeys(pv, reclaim.NamespaceLabelsAnnotation), cfg.OwnerLabel, cfg.NsLabel) {
+		if _, ok := pv.GetLabels()[key]; !ok || keepLabels[key] || !owned.labels[key] {
+			continue
+		}
+		delete(pv.Labels, key)
+		removed = append(removed, fmt.Sprintf("label %s-", key))
+	}
+	for _, key := range reclaim.GetTrackedKeys(pv, reclaim.NamespaceAnnotationsAnnotation) {
+		if _, ok := pv.GetAnnotations()[key]; !ok || keepAnnotations[key] || !owned.annotations[key] {
+			continue
+		}
+		delete(pv.Annotations, key)
+		removed = append(removed, fmt.Sprintf("annotation %s-", key))
+	}
+
+	reclaim.SetTrackedKeys(pv, reclaim.NamespaceLabelsAnnotation, presentKeys(pv.GetLabels(), keepLabels))
+	reclaim.SetTrackedKeys(pv, reclaim.NamespaceAnnotationsAnnotation, presentKeys(pv.GetAnnotations(), keepAnnotations))
+
+	if len(removed) > 0 {
+		log.Info("Removing metadata no longer copied from the Namespace", "removed", removed)
+	}
+
+	return removed
+}
+
+// presentKeys returns the keys that are both kept and set
+func presentKeys(values map[string]string, keep map[string]bool) []string {
+	var keys []string
+	for key, ok := range keep {
+		if _, set := values[key]; ok && set {
+			keys = append(keys, key)
 		}
+	}
+	return keys
+}
+
+// namespacePropagationChanged reports whether a Namespace update changes the labels or annotations propagated
+// to its Persistent Volumes
+func namespacePropagationChanged(cfg config.ControllerConfig, old, new metav1.Object) bool {
+	labelRules, _ := propagation.ParseRules(cfg.NamespaceLabels)
+	annotationRules, _ := propagation.ParseRules(cfg.NamespaceAnnotations)
 
+	return !reflect.DeepEqual(propagation.Apply(labelRules, old.GetLabels()), propagation.Apply(labelRules, new.GetLabels())) ||
+		!reflect.DeepEqual(propagation.Apply(annotationRules, old.GetAnnotations()), propagation.Apply(annotationRules, new.GetAnnotations()))
+}
+
+// reconcileUnclaimed snapshots the provenance of a Persistent Volume released from its claim, and sets the
+// unclaimed label when enabled, or removes a previously set label when disabled
+func (r *PersistentVolumeReconciler) reconcileUnclaimed(ctx context.Context, log logr.Logger, cfg config.ControllerConfig, pv, base *corev1.PersistentVolume) (ctrl.Result, error) {
+	r.snapshotProvenance(ctx, log, cfg, pv)
+
+	if cfg.UnclaimedSet && cfg.UnclaimedLabel != "" {
+		if pv.Labels == nil {
+			pv.Labels = make(map[string]string)
+		}
+		pv.Labels[cfg.UnclaimedLabel] = "true"
+	} else if cfg.UnclaimedLabel != "" {
+		delete(pv.Labels, cfg.UnclaimedLabel)
+	}
+
+	keepLabels, keepAnnotations := releasedMetadataKeys(cfg, pv)
+	removed := pruneNamespaceMetadata(log, cfg, pv, base, keepLabels, keepAnnotations)
+
+	if _, err := applyPersistentVolume(ctx, r, "PersistentVolume", pv, base); err != nil {
+		if isFieldManagerConflict(err) {
+			log.Info("Unclaimed PV fields are managed by another field manager, skipping", "reason", err.Error())
+			return ctrl.Result{}, nil
+		}
 		return reconcile.Result{}, fmt.Errorf("could not update PV: %+v", err)
 	}
+
+	if len(removed) > 0 {
+		r.Recorder.Eventf(pv, corev1.EventTypeNormal, EventReasonLabelsRemoved, "Removed %s no longer copied from the Namespace", strings.Join(removed, ", "))
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// snapshotProvenance records when a Persistent Volume was released from its claim, along with the claim it
+// was bound to. The provenance recorded while the PV was bound is kept, since the claim and its Namespace
+// may already be gone, and is completed from the claimRef and the owner label otherwise.
+func (r *PersistentVolumeReconciler) snapshotProvenance(ctx context.Context, log logr.Logger, cfg config.ControllerConfig, pv *corev1.PersistentVolume) {
+	if pv.Spec.ClaimRef == nil || (pv.Status.Phase != corev1.VolumeReleased && pv.Status.Phase != corev1.VolumeFailed) {
+		return
+	}
+
+	provenance := reclaim.GetProvenance(pv)
+	if !provenance.ReleasedAt.IsZero() {
+		return
+	}
+
+	if provenance.Namespace == "" {
+		provenance.Namespace = pv.Spec.ClaimRef.Namespace
+	}
+	if provenance.Claim == "" {
+		provenance.Claim = pv.Spec.ClaimRef.Name
+	}
+	if provenance.Owner == "" {
+		provenance.Owner = buildNamespaceMap(ctx, r, log, pv.Spec.ClaimRef, cfg.OwnerLabel)
+	}
+	if provenance.Owner == "" && cfg.OwnerLabel != "" {
+		provenance.Owner = pv.GetLabels()[cfg.OwnerLabel]
+	}
+	if provenance.StorageClass == "" {
+		provenance.StorageClass = pv.Spec.StorageClassName
+	}
+	provenance.ReleasedAt = time.Now()
+
+	log.Info("Recording provenance of released PV", "namespace", provenance.Namespace, "pvc", provenance.Claim, "owner", provenance.Owner)
+	reclaim.SetProvenance(pv, provenance)
+}
+
+// recordExternalPolicyChange records a reclaim policy change made by something other than volrec, ie. an admin
+// editing the PV, in the audit annotations. The change is attributed to the field manager that made it.
+func recordExternalPolicyChange(log logr.Logger, pv *corev1.PersistentVolume) {
+	applied, ok := pv.GetAnnotations()[reclaim.AppliedPolicyAnnotation]
+	if !ok || applied == "" || corev1.PersistentVolumeReclaimPolicy(applied) == pv.Spec.PersistentVolumeReclaimPolicy {
+		return
+	}
+
+	manager, changedAt := reclaimPolicyManager(pv)
+	if manager == "" {
+		manager = "unknown"
+	}
+	log.Info("Reclaim policy on PV was changed outside of volrec", "from", applied, "to", pv.Spec.PersistentVolumeReclaimPolicy, "field-manager", manager)
+
+	change := reclaim.PolicyChange{
+		Source:     fmt.Sprintf("external change by field manager %s", manager),
+		From:       corev1.PersistentVolumeReclaimPolicy(applied),
+		To:         pv.Spec.PersistentVolumeReclaimPolicy,
+		Time:       changedAt,
+		Controller: manager,
+	}
+	if provenance := reclaim.GetProvenance(pv); provenance.Claim != "" {
+		change.Claim = fmt.Sprintf("%s/%s", provenance.Namespace, provenance.Claim)
+	}
+	recordPolicyChange(log, pv, change)
+}
+
 // SetupWithManager adds a Kubernetes controller instance to a Controller Manager
 func (r *PersistentVolumeReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.PersistentVolume{}).
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.PersistentVolume{})
+
+	if r.ResyncEvents != nil {
+		b = b.Watches(&source.Channel{Source: r.ResyncEvents}, &handler.EnqueueRequestForObject{})
+	}
+
+	c, err := b.
 		WithEventFilter(predicate.Funcs{
 			UpdateFunc: func(e event.UpdateEvent) bool {
-				// Ignore updates to CR status in which case metadata.Generation does not change
+				// Ignore updates to CR status in which case metadata.Generation does not change, except for
+				// PV's being bound to or released from a claim, or being locked or unlocked
+				if oldPV, ok := e.ObjectOld.(*corev1.PersistentVolume); ok {
+					newPV := e.ObjectNew.(*corev1.PersistentVolume)
+					if oldPV.Status.Phase != newPV.Status.Phase || !reflect.DeepEqual(oldPV.Spec.ClaimRef, newPV.Spec.ClaimRef) ||
+						oldPV.Spec.PersistentVolumeReclaimPolicy != newPV.Spec.PersistentVolumeReclaimPolicy ||
+						reclaim.IsLocked(oldPV) != reclaim.IsLocked(newPV) {
+						return true
+					}
+				}
 				return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration()
 			},
 			DeleteFunc: func(e event.DeleteEvent) bool {
@@ -181,5 +529,61 @@ func (r *PersistentVolumeReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				return false
 			},
 		}).
-		Complete(r)
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	// Enqueue the PV bound to a claim when the claim is bound or its labels change
+	if err := c.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: volumesForClaim(r, r.Log)},
+		predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return false
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				oldPVC, oldOK := e.ObjectOld.(*corev1.PersistentVolumeClaim)
+				newPVC, newOK := e.ObjectNew.(*corev1.PersistentVolumeClaim)
+				if !oldOK || !newOK {
+					return false
+				}
+				return oldPVC.Spec.VolumeName != newPVC.Spec.VolumeName ||
+					!reflect.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels())
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return false
+			},
+		}); err != nil {
+		return err
+	}
+
+	// Enqueue every PV of a StorageClass when the StorageClass is locked or unlocked
+	if err := c.Watch(&source.Kind{Type: &storagev1.StorageClass{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: volumesForStorageClass(r, r.Log)},
+		lockChanged); err != nil {
+		return err
+	}
+
+	// Enqueue every PV bound in a Namespace when the Namespace owner, or propagated metadata, changes
+	return c.Watch(&source.Kind{Type: &corev1.Namespace{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: volumesForNamespace(r, r.Log)},
+		predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return false
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				cfg := config.Get()
+				return e.MetaOld.GetLabels()[cfg.OwnerLabel] != e.MetaNew.GetLabels()[cfg.OwnerLabel] ||
+					namespacePropagationChanged(cfg, e.MetaOld, e.MetaNew)
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return false
+			},
+		})
 }
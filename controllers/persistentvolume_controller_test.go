@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"twr.dev/volrec/pkg/config"
+	"twr.dev/volrec/pkg/reclaim"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("PersistentVolume Controller", func() {
+	const (
+		unclaimedLabel = "k8s.twr.dev/unclaimed"
+		nsLabel        = "k8s.twr.dev/owning-namespace"
+		ownerLabel     = "k8s.twr.dev/owner"
+	)
+
+	var (
+		ctx = context.Background()
+		r   *PersistentVolumeReconciler
+	)
+
+	newPV := func(name string, claimRef *corev1.ObjectReference, phase corev1.PersistentVolumePhase) *corev1.PersistentVolume {
+		pv := &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.PersistentVolumeSpec{
+				Capacity:    corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{Path: "/tmp/" + name},
+				},
+				ClaimRef:                      claimRef,
+				PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			},
+		}
+		Expect(k8sClient.Create(ctx, pv)).To(Succeed())
+
+		pv.Status.Phase = phase
+		Expect(k8sClient.Status().Update(ctx, pv)).To(Succeed())
+
+		return pv
+	}
+
+	reconcilePV := func(name string) *corev1.PersistentVolume {
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: client.ObjectKey{Name: name}})
+		Expect(err).NotTo(HaveOccurred())
+
+		var pv corev1.PersistentVolume
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: name}, &pv)).To(Succeed())
+		return &pv
+	}
+
+	BeforeEach(func() {
+		config.Set(config.ControllerConfig{
+			ReclaimPolicyLabel: "storage.k8s.twr.dev/reclaim-policy",
+			OwnerLabel:         ownerLabel,
+			OwnerSet:           true,
+			NsLabel:            nsLabel,
+			NsSet:              true,
+			UnclaimedLabel:     unclaimedLabel,
+			UnclaimedSet:       true,
+		})
+
+		r = &PersistentVolumeReconciler{
+			Client:   k8sClient,
+			Log:      logf.Log.WithName("controllers").WithName("PersistentVolume"),
+			Recorder: record.NewFakeRecorder(100),
+		}
+	})
+
+	It("labels an Available PV without a claimRef as unclaimed", func() {
+		newPV("pv-available", nil, corev1.VolumeAvailable)
+
+		pv := reconcilePV("pv-available")
+		Expect(pv.Labels).To(HaveKeyWithValue(unclaimedLabel, "true"))
+		Expect(pv.Labels).NotTo(HaveKey(nsLabel))
+	})
+
+	It("labels a Released PV whose claim was deleted as unclaimed", func() {
+		newPV("pv-released", &corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: "default", Name: "deleted-claim", UID: "1234"}, corev1.VolumeReleased)
+
+		pv := reconcilePV("pv-released")
+		Expect(pv.Labels).To(HaveKeyWithValue(unclaimedLabel, "true"))
+		Expect(pv.Annotations).To(HaveKeyWithValue(reclaim.ClaimNamespaceAnnotation, "default"))
+		Expect(pv.Annotations).To(HaveKeyWithValue(reclaim.ClaimNameAnnotation, "deleted-claim"))
+		Expect(pv.Annotations).To(HaveKey(reclaim.ReleasedAtAnnotation))
+	})
+
+	It("labels a Failed PV whose claim was deleted as unclaimed", func() {
+		newPV("pv-failed", &corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: "default", Name: "failed-claim", UID: "5678"}, corev1.VolumeFailed)
+
+		pv := reconcilePV("pv-failed")
+		Expect(pv.Labels).To(HaveKeyWithValue(unclaimedLabel, "true"))
+	})
+
+	It("picks up a PV once it is Bound and removes the unclaimed label", func() {
+		newPV("pv-bound", nil, corev1.VolumeAvailable)
+		Expect(reconcilePV("pv-bound").Labels).To(HaveKeyWithValue(unclaimedLabel, "true"))
+
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "bound-claim", Namespace: "default"},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				},
+				VolumeName: "pv-bound",
+			},
+		}
+		Expect(k8sClient.Create(ctx, pvc)).To(Succeed())
+
+		var pv corev1.PersistentVolume
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "pv-bound"}, &pv)).To(Succeed())
+		pv.Spec.ClaimRef = &corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID}
+		Expect(k8sClient.Update(ctx, &pv)).To(Succeed())
+		pv.Status.Phase = corev1.VolumeBound
+		Expect(k8sClient.Status().Update(ctx, &pv)).To(Succeed())
+
+		bound := reconcilePV("pv-bound")
+		Expect(bound.Labels).NotTo(HaveKey(unclaimedLabel))
+		Expect(bound.Labels).To(HaveKeyWithValue(nsLabel, "default"))
+		Expect(bound.Annotations).To(HaveKeyWithValue(reclaim.ClaimNameAnnotation, "bound-claim"))
+		Expect(bound.Annotations).NotTo(HaveKey(reclaim.ReleasedAtAnnotation))
+	})
+
+	It("removes the labels copied from the Namespace once their source is gone", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "owned", Labels: map[string]string{ownerLabel: "team-a"}}}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "owned-claim", Namespace: ns.Name},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				},
+				VolumeName: "pv-owned",
+			},
+		}
+		Expect(k8sClient.Create(ctx, pvc)).To(Succeed())
+		newPV("pv-owned", &corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID}, corev1.VolumeBound)
+
+		pv := reconcilePV("pv-owned")
+		Expect(pv.Labels).To(HaveKeyWithValue(ownerLabel, "team-a"))
+		Expect(pv.Annotations).To(HaveKeyWithValue(reclaim.NamespaceLabelsAnnotation, ownerLabel+","+nsLabel))
+
+		// The owner label is removed from the Namespace
+		delete(ns.Labels, ownerLabel)
+		Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+
+		pv = reconcilePV("pv-owned")
+		Expect(pv.Labels).NotTo(HaveKey(ownerLabel))
+		Expect(pv.Labels).To(HaveKeyWithValue(nsLabel, ns.Name))
+
+		// Setting the owning Namespace label is disabled
+		cfg := config.Get()
+		cfg.NsSet = false
+		config.Set(cfg)
+
+		pv = reconcilePV("pv-owned")
+		Expect(pv.Labels).NotTo(HaveKey(nsLabel))
+		Expect(pv.Annotations).NotTo(HaveKey(reclaim.NamespaceLabelsAnnotation))
+	})
+})
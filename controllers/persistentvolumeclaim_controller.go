@@ -19,33 +19,63 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	reclaimv1alpha1 "twr.dev/volrec/api/v1alpha1"
 	"twr.dev/volrec/pkg/config"
+	"twr.dev/volrec/pkg/metrics"
+	"twr.dev/volrec/pkg/reclaim"
+	"twr.dev/volrec/pkg/version"
 
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 )
 
 // PersistentVolumeClaimReconciler reconciles a PersistentVolumeClaim object
 type PersistentVolumeClaimReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// ResyncEvents optionally requeues objects outside of the normal watch, ie. on config reload
+	ResyncEvents <-chan event.GenericEvent
+
+	// reclaimLabels holds the reclaim policy label last seen on each bound claim, by UID, so a policy that
+	// already matches is only recorded as an Event when the label was just changed rather than on every resync
+	reclaimLabels sync.Map
+	// startedAt is when the controller was set up, claims created since then are new rather than just unseen
+	startedAt time.Time
+	// reportedInvalid holds the VolumeReclaimRules and VolumeReclaimRestrictions, by UID and generation, already
+	// reported as invalid through an Event
+	reportedInvalid sync.Map
 }
 
 // +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=reclaim.storage.k8s.twr.dev,resources=volumereclaimrules,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile reconciles Kubernetes Persistent Volumes Claims for the Volume Reclaim Controller (VRC) Controller
 func (r *PersistentVolumeClaimReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 	log := r.Log.WithValues("pvc", req.NamespacedName)
+	cfg := config.Get()
 
 	var (
 		pv  corev1.PersistentVolume
@@ -59,64 +89,407 @@ func (r *PersistentVolumeClaimReconciler) Reconcile(req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	if pvc.Spec.VolumeName != "" {
+	// A claim requesting a Released PV sets spec.volumeName to it, so it's handled before it looks bound
+	if _, ok := pvc.GetAnnotations()[reclaim.RebindAnnotation]; ok && cfg.RebindEnabled && pvc.Status.Phase != corev1.ClaimBound {
+		return r.rebind(ctx, log, &pvc)
+	}
 
-		log.Info("PVC is bound to volume", "volume-name", pvc.Spec.VolumeName)
+	volumeName, err := boundVolumeName(ctx, r, &pvc)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 
-		if err := r.Get(ctx, client.ObjectKey{Name: pvc.Spec.VolumeName}, &pv); err != nil {
+	if volumeName != "" {
+
+		log.Info("PVC is bound to volume", "volume-name", volumeName)
+
+		labelChanged := r.reclaimLabelChanged(&pvc, cfg.ReclaimPolicyLabel)
+
+		if err := r.Get(ctx, client.ObjectKey{Name: volumeName}, &pv); err != nil {
 			if apierrors.IsNotFound(err) {
 				return ctrl.Result{}, nil
 			}
 			return ctrl.Result{}, client.IgnoreNotFound(err)
 		}
+		base := pv.DeepCopy()
+
+		locked, lockSource, err := volumeLocked(ctx, r, &pv)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if locked {
+			log.Info("PV is administratively locked, skipping", "pv", pv.Name, "lock-source", lockSource)
+			r.Recorder.Eventf(&pvc, corev1.EventTypeWarning, EventReasonReclaimPolicyLocked, "Reclaim policy on PV %s is administratively locked by %s and won't be changed", pv.Name, lockSource)
+			return ctrl.Result{}, nil
+		}
+
+		// Claim metadata is propagated whatever the reclaim policy, and applied separately so it isn't held up by it
+		if err := r.propagateClaim(ctx, log, cfg, &pv, &pvc); err != nil {
+			return ctrl.Result{}, err
+		}
+		base = pv.DeepCopy()
+
+		resolved, err := r.resolveReclaimPolicy(ctx, cfg, &pv, &pvc)
+		if err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		reclaimPolicyFromPVCLabel, policySource := resolved.value, resolved.source
 
-		reclaimPolicyFromPVCLabel := pvc.GetLabels()[config.VolrecConfig.ReclaimPolicyLabel]
-		log.Info("Reconciling PV", "policy-from-label", reclaimPolicyFromPVCLabel)
+		log.Info("Reconciling PV", "policy-from-label", reclaimPolicyFromPVCLabel, "policy-source", policySource)
 
-		// if value in label does not match value on PV, set it
+		// Nothing is requested, or the request is ignored, but a protected volume still never keeps a destructive
+		// reclaim policy, ie. Delete set by its provisioner
+		var reclaimPolicy corev1.PersistentVolumeReclaimPolicy
 		if reclaimPolicyFromPVCLabel == "" {
 			log.Info("PVC does not have reclaim policy label", "namespace", pvc.Namespace)
-			return ctrl.Result{}, nil
-		} else if fmt.Sprintf("%v", pv.Spec.PersistentVolumeReclaimPolicy) != reclaimPolicyFromPVCLabel {
-			log.Info("Setting reclaim policy to match PVC label", "pv", pv.Name, "policy-from-pvc-label", reclaimPolicyFromPVCLabel, "policy-from-pv", pv.Spec.PersistentVolumeReclaimPolicy)
-			// Update the reclaim policy from label value
-			pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimPolicy(reclaimPolicyFromPVCLabel)
-		} else {
+		} else if reclaimPolicy, err = reclaim.ParsePolicy(reclaimPolicyFromPVCLabel); err != nil {
+			// Nothing to retry here, the PVC is requeued when the label is corrected
+			log.Error(err, "Invalid reclaim policy label", "pv", pv.Name, "policy-source", policySource)
+			metrics.InvalidReclaimPolicies.WithLabelValues(pvc.Namespace).Inc()
+			r.Recorder.Eventf(&pvc, corev1.EventTypeWarning, EventReasonInvalidReclaimPolicy, "Ignoring %s: %v", policySource, err)
+			reclaimPolicy = ""
+		} else if resolved.rule == "" {
+			// Restrictions limit what a Namespace can request, rules are set by admins so they aren't restricted
+			denial, err := r.checkRestrictions(ctx, reclaimPolicy, &pv, &pvc)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if denial != nil {
+				log.Info("Reclaim policy not allowed, ignoring", "pv", pv.Name, "policy-source", policySource, "reason", denial.Error())
+				r.Recorder.Eventf(&pvc, corev1.EventTypeWarning, EventReasonReclaimPolicyNotAllowed, "Ignoring %s: %v", policySource, denial)
+				reclaimPolicy = ""
+			}
+		}
+
+		// Protected volumes never get, or keep, a destructive reclaim policy, Retain is applied instead
+		requested := reclaimPolicy
+		if requested == "" {
+			requested = pv.Spec.PersistentVolumeReclaimPolicy
+		}
+		if reclaim.IsDestructive(requested) {
+			blocker, err := protectedBy(ctx, r, &pvc)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if blocker != "" {
+				log.Info("Volume is protected, applying Retain instead", "pv", pv.Name, "requested-policy", requested, "protected-by", blocker)
+				if reclaimPolicy == "" {
+					r.Recorder.Eventf(&pvc, corev1.EventTypeWarning, EventReasonReclaimPolicyProtected, "Reclaim policy %s on PV %s is blocked by %s, applying %s instead", requested, pv.Name, blocker, corev1.PersistentVolumeReclaimRetain)
+				} else {
+					r.Recorder.Eventf(&pvc, corev1.EventTypeWarning, EventReasonReclaimPolicyProtected, "Reclaim policy %s from %s on PV %s is blocked by %s, applying %s instead", reclaimPolicy, policySource, pv.Name, blocker, corev1.PersistentVolumeReclaimRetain)
+				}
+				// Retain comes from the blocker, not from whatever requested the destructive policy
+				reclaimPolicy, policySource = corev1.PersistentVolumeReclaimRetain, blocker
+				resolved.rule, resolved.source = "", blocker
+			}
+		}
+
+		if reclaimPolicy == "" {
+			if reclaimPolicyFromPVCLabel != "" {
+				return ctrl.Result{}, nil
+			}
+			return r.clearAppliedRule(ctx, log, &pv)
+		}
+
+		// A snapshot pending for an earlier request to switch to Delete is stale once another policy is requested
+		if _, ok := pv.GetAnnotations()[reclaim.PendingSnapshotAnnotation]; ok && reclaimPolicy != corev1.PersistentVolumeReclaimDelete {
+			log.Info("Discarding pending VolumeSnapshot", "pv", pv.Name, "snapshot", pv.Annotations[reclaim.PendingSnapshotAnnotation])
+			delete(pv.Annotations, reclaim.PendingSnapshotAnnotation)
+			if _, err := applyPersistentVolume(ctx, r, "PersistentVolumeClaim", &pv, base); err != nil {
+				return reconcile.Result{}, fmt.Errorf("could not update PV: %+v", err)
+			}
+			base = pv.DeepCopy()
+		}
+
+		if pv.Spec.PersistentVolumeReclaimPolicy == reclaimPolicy && pv.GetAnnotations()[reclaim.AppliedRuleAnnotation] == resolved.rule {
 			log.Info("Reclaim policy on PV already matches PVC label", "pv", pv.Name, "policy-from-pvc-label", reclaimPolicyFromPVCLabel, "policy-from-pv", pv.Spec.PersistentVolumeReclaimPolicy)
+			metrics.NoopReconciles.WithLabelValues("PersistentVolumeClaim").Inc()
+			if labelChanged {
+				r.Recorder.Eventf(&pvc, corev1.EventTypeNormal, EventReasonReclaimPolicyUnchanged, "Reclaim policy on PV %s is already %s", pv.Name, reclaimPolicy)
+			}
+			return ctrl.Result{}, nil
+		}
+
+		// Snapshot the volume before switching it to Delete, the policy is only changed once the snapshot is ready.
+		// Nothing is created in dry-run, so the change is reported as if the snapshot were ready.
+		if cfg.SnapshotBeforeDelete && !cfg.DryRun && reclaimPolicy == corev1.PersistentVolumeReclaimDelete {
+			snapshot, retry, err := r.snapshotBeforeDelete(ctx, log, &pv, &pvc)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if snapshot == "" {
+				return ctrl.Result{RequeueAfter: retry}, nil
+			}
+
+			delete(pv.Annotations, reclaim.PendingSnapshotAnnotation)
+			pv.Annotations[reclaim.SnapshotAnnotation] = snapshot
+		}
+
+		log.Info("Setting reclaim policy to match PVC label", "pv", pv.Name, "policy-from-pvc-label", reclaimPolicyFromPVCLabel, "policy-from-pv", pv.Spec.PersistentVolumeReclaimPolicy)
+		previousPolicy := pv.Spec.PersistentVolumeReclaimPolicy
+		// Update the reclaim policy from label value
+		pv.Spec.PersistentVolumeReclaimPolicy = reclaimPolicy
+		setAppliedRule(&pv, resolved.rule)
+		if previousPolicy != reclaimPolicy {
+			recordPolicyChange(log, &pv, reclaim.PolicyChange{
+				Claim:  req.NamespacedName.String(),
+				Source: policySource,
+				From:   previousPolicy,
+				To:     reclaimPolicy,
+			})
+		}
+
+		// Patch Persistent Volume
+		if _, err := applyPersistentVolume(ctx, r, "PersistentVolumeClaim", &pv, base); err != nil {
+			if isFieldManagerConflict(err) {
+				// Retrying won't help until the other field manager gives up the field
+				log.Info("Reclaim policy on PV is managed by another field manager, skipping", "pv", pv.Name, "reason", err.Error())
+				r.Recorder.Eventf(&pvc, corev1.EventTypeWarning, EventReasonFieldConflict, "Reclaim policy on PV %s is managed by another field manager and won't be changed to %s: %v", pv.Name, reclaimPolicy, err)
+				r.Recorder.Eventf(&pv, corev1.EventTypeWarning, EventReasonFieldConflict, "Reclaim policy is managed by another field manager and won't be changed to %s: %v", reclaimPolicy, err)
+				return ctrl.Result{}, nil
+			}
+			if apierrors.IsConflict(err) {
+				// The PV changed since it was read, retry with the latest version
+				return ctrl.Result{Requeue: true}, nil
+			}
+			r.Recorder.Eventf(&pvc, corev1.EventTypeWarning, EventReasonUpdateFailed, "Unable to set reclaim policy on PV %s to %s: %v", pv.Name, reclaimPolicy, err)
+			r.Recorder.Eventf(&pv, corev1.EventTypeWarning, EventReasonUpdateFailed, "Unable to set reclaim policy to %s from %s: %v", reclaimPolicy, policySource, err)
+			return reconcile.Result{}, fmt.Errorf("could not update PV: %+v", err)
+		}
+
+		// Changes aren't made in dry-run, they're reported by applyPersistentVolume instead
+		if previousPolicy == reclaimPolicy || cfg.DryRun {
+			return ctrl.Result{}, nil
 		}
 
+		metrics.ReclaimPolicyChanges.WithLabelValues(string(previousPolicy), string(reclaimPolicy), pv.Spec.StorageClassName).Inc()
+		r.Recorder.Eventf(&pvc, corev1.EventTypeNormal, EventReasonReclaimPolicyApplied, "Reclaim policy on PV %s changed from %s to %s by %s", pv.Name, previousPolicy, reclaimPolicy, policySource)
+		r.Recorder.Eventf(&pv, corev1.EventTypeNormal, EventReasonReclaimPolicyApplied, "Reclaim policy changed from %s to %s by %s", previousPolicy, reclaimPolicy, policySource)
+
 	} else {
-		// Requeue to process PVC again once it is bound to a PV
+		// The PVC is enqueued again by the PV watch once it is bound to a PV
 		log.Info("PVC not bound to volume yet", "namespace", pvc.Namespace)
-		return reconcile.Result{Requeue: true}, nil
+		if pvc.GetLabels()[cfg.ReclaimPolicyLabel] != "" {
+			r.Recorder.Event(&pvc, corev1.EventTypeNormal, EventReasonPendingBinding, "PVC is not bound to a PV yet, the reclaim policy will be applied once it is bound")
+		}
 	}
 
-	// Update Persistent Volume
-	err := r.Update(context.TODO(), &pv)
-	if err != nil {
+	return ctrl.Result{}, nil
+}
 
-		if apierrors.IsConflict(err) {
-			return reconcile.Result{Requeue: true}, nil
-		}
+// clearAppliedRule removes the applied rule annotation from a Persistent Volume that no longer matches a rule.
+// The reclaim policy itself is left as is.
+func (r *PersistentVolumeClaimReconciler) clearAppliedRule(ctx context.Context, log logr.Logger, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	if _, ok := pv.GetAnnotations()[reclaim.AppliedRuleAnnotation]; !ok {
+		return ctrl.Result{}, nil
+	}
 
+	log.Info("Removing applied rule annotation from PV", "pv", pv.Name)
+	base := pv.DeepCopy()
+	setAppliedRule(pv, "")
+
+	if _, err := applyPersistentVolume(ctx, r, "PersistentVolumeClaim", pv, base); err != nil {
+		if isFieldManagerConflict(err) {
+			log.Info("Applied rule annotation on PV is managed by another field manager, skipping", "pv", pv.Name, "reason", err.Error())
+			return ctrl.Result{}, nil
+		}
 		return reconcile.Result{}, fmt.Errorf("could not update PV: %+v", err)
 	}
+
 	return ctrl.Result{}, nil
 }
 
+// setAppliedRule records the VolumeReclaimRule that set the reclaim policy on a Persistent Volume, an
+// empty rule removes the annotation
+func setAppliedRule(pv *corev1.PersistentVolume, rule string) {
+	if rule == "" {
+		delete(pv.Annotations, reclaim.AppliedRuleAnnotation)
+		return
+	}
+
+	if pv.Annotations == nil {
+		pv.Annotations = make(map[string]string)
+	}
+	pv.Annotations[reclaim.AppliedRuleAnnotation] = rule
+}
+
+// recordPolicyChange records who changed the reclaim policy on a Persistent Volume, and when, in its audit
+// annotations. The change is attributed to this release of volrec, and made now, unless it says otherwise.
+func recordPolicyChange(log logr.Logger, pv *corev1.PersistentVolume, change reclaim.PolicyChange) {
+	if change.Time.IsZero() {
+		change.Time = time.Now()
+	}
+	if change.Controller == "" {
+		change.Controller = version.Controller()
+	}
+
+	if err := reclaim.RecordPolicyChange(pv, change, config.Get().ReclaimHistoryLimit); err != nil {
+		log.Error(err, "Replacing invalid reclaim policy history on PV", "pv", pv.Name)
+	}
+}
+
+// claimForVolume maps a Persistent Volume to the claim it is bound to
+func claimForVolume(obj handler.MapObject) []reconcile.Request {
+	pv, ok := obj.Object.(*corev1.PersistentVolume)
+	if !ok || pv.Spec.ClaimRef == nil {
+		return nil
+	}
+
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: pv.Spec.ClaimRef.Namespace, Name: pv.Spec.ClaimRef.Name}},
+	}
+}
+
+// reclaimLabelChanged records the reclaim policy label of a bound claim and reports whether it changed since the
+// claim was last reconciled. Every existing claim is reconciled once at startup, so a claim that hasn't been seen
+// before only counts as changed if it was created after the controller was set up.
+func (r *PersistentVolumeClaimReconciler) reclaimLabelChanged(pvc *corev1.PersistentVolumeClaim, label string) bool {
+	value := pvc.GetLabels()[label]
+	previous, seen := r.reclaimLabels.Load(pvc.UID)
+	r.reclaimLabels.Store(pvc.UID, value)
+
+	if !seen {
+		return pvc.CreationTimestamp.Time.After(r.startedAt)
+	}
+
+	return previous.(string) != value
+}
+
 // SetupWithManager adds a Kubernetes controller instance to a Controller Manager
 func (r *PersistentVolumeClaimReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.PersistentVolumeClaim{}).
+	r.startedAt = time.Now()
+
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.PersistentVolumeClaim{})
+
+	if r.ResyncEvents != nil {
+		b = b.Watches(&source.Channel{Source: r.ResyncEvents}, &handler.EnqueueRequestForObject{})
+	}
+
+	c, err := b.
 		WithEventFilter(predicate.Funcs{
 			UpdateFunc: func(e event.UpdateEvent) bool {
-				// Ignore updates to CR status in which case metadata.Generation does not change
-				return e.MetaOld.GetLabels()[config.VolrecConfig.ReclaimPolicyLabel] != e.MetaNew.GetLabels()[config.VolrecConfig.ReclaimPolicyLabel]
+				cfg := config.Get()
+				return e.MetaOld.GetLabels()[cfg.ReclaimPolicyLabel] != e.MetaNew.GetLabels()[cfg.ReclaimPolicyLabel] ||
+					claimPropagationChanged(cfg, e.MetaOld, e.MetaNew) ||
+					e.MetaOld.GetLabels()[reclaim.ProtectedLabel] != e.MetaNew.GetLabels()[reclaim.ProtectedLabel] ||
+					e.MetaOld.GetAnnotations()[reclaim.RebindAnnotation] != e.MetaNew.GetAnnotations()[reclaim.RebindAnnotation]
 			},
 			DeleteFunc: func(e event.DeleteEvent) bool {
 				//return !e.DeleteStateUnknown
 				return false
 			},
 		}).
-		Complete(r)
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	// Enqueue the claims mounted by the Pods of a StatefulSet when it is marked as critical, or a new Pod is
+	// started, so a destructive reclaim policy is replaced by Retain
+	if err := c.Watch(&source.Kind{Type: &appsv1.StatefulSet{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: claimsForStatefulSet(r, r.Log)},
+		predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return reclaim.IsCritical(e.Meta)
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return reclaim.IsCritical(e.MetaOld) != reclaim.IsCritical(e.MetaNew)
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return false
+			},
+		}); err != nil {
+		return err
+	}
+	if err := c.Watch(&source.Kind{Type: &corev1.Pod{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(claimsForPod)},
+		predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				owner := metav1.GetControllerOf(e.Meta)
+				return owner != nil && owner.Kind == "StatefulSet"
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return false
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return false
+			},
+		}); err != nil {
+		return err
+	}
+
+	// Enqueue the claims in a Namespace without their own reclaim policy label when its default changes
+	if err := c.Watch(&source.Kind{Type: &corev1.Namespace{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: claimsForNamespace(r, r.Log)},
+		predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return false
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				defaultReclaimPolicyLabel := config.Get().DefaultReclaimPolicyLabel
+				return e.MetaOld.GetLabels()[defaultReclaimPolicyLabel] != e.MetaNew.GetLabels()[defaultReclaimPolicyLabel]
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return false
+			},
+		}); err != nil {
+		return err
+	}
+
+	// Enqueue every bound claim whenever a VolumeReclaimRule is created, changed or deleted
+	if err := c.Watch(&source.Kind{Type: &reclaimv1alpha1.VolumeReclaimRule{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: claimsForRules(r, r.Log)},
+		predicate.GenerationChangedPredicate{}); err != nil {
+		return err
+	}
+
+	// Enqueue every bound claim whenever a VolumeReclaimRestriction is created, changed or deleted, so claims
+	// are rechecked against what is allowed now
+	if err := c.Watch(&source.Kind{Type: &reclaimv1alpha1.VolumeReclaimRestriction{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: claimsForRestrictions(r, r.Log)},
+		predicate.GenerationChangedPredicate{}); err != nil {
+		return err
+	}
+
+	// Enqueue the claim of every PV of a StorageClass when the StorageClass is locked or unlocked
+	if err := c.Watch(&source.Kind{Type: &storagev1.StorageClass{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: claimsForStorageClass(r, r.Log)},
+		lockChanged); err != nil {
+		return err
+	}
+
+	// Enqueue the claim when its PV is bound, the reclaim policy can only be applied from then on, or when its
+	// PV is locked or unlocked
+	return c.Watch(&source.Kind{Type: &corev1.PersistentVolume{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(claimForVolume)},
+		predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return false
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				oldPV, oldOK := e.ObjectOld.(*corev1.PersistentVolume)
+				newPV, newOK := e.ObjectNew.(*corev1.PersistentVolume)
+				if !oldOK || !newOK {
+					return false
+				}
+				return (oldPV.Status.Phase != newPV.Status.Phase && newPV.Status.Phase == corev1.VolumeBound) ||
+					reclaim.IsLocked(oldPV) != reclaim.IsLocked(newPV)
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return false
+			},
+		})
 }
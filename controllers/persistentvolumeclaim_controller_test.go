@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"twr.dev/volrec/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("PersistentVolumeClaim Controller", func() {
+	const reclaimPolicyLabel = "storage.k8s.twr.dev/reclaim-policy"
+
+	var (
+		ctx = context.Background()
+		r   *PersistentVolumeClaimReconciler
+	)
+
+	BeforeEach(func() {
+		config.Set(config.ControllerConfig{
+			ReclaimPolicyLabel: reclaimPolicyLabel,
+		})
+
+		r = &PersistentVolumeClaimReconciler{
+			Client:   k8sClient,
+			Log:      logf.Log.WithName("controllers").WithName("PersistentVolumeClaim"),
+			Recorder: record.NewFakeRecorder(100),
+		}
+	})
+
+	It("changes the reclaim policy of a PV created by another field manager", func() {
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "provisioned-claim",
+				Namespace: "default",
+				Labels:    map[string]string{reclaimPolicyLabel: string(corev1.PersistentVolumeReclaimRetain)},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				},
+				VolumeName: "pv-provisioned",
+			},
+		}
+		Expect(k8sClient.Create(ctx, pvc)).To(Succeed())
+
+		// The provisioner creating the PV owns its reclaim policy
+		pv := &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-provisioned"},
+			Spec: corev1.PersistentVolumeSpec{
+				Capacity:    corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{Path: "/tmp/pv-provisioned"},
+				},
+				ClaimRef:                      &corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID},
+				PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+			},
+		}
+		Expect(k8sClient.Create(ctx, pv, client.FieldOwner("external-provisioner"))).To(Succeed())
+		pv.Status.Phase = corev1.VolumeBound
+		Expect(k8sClient.Status().Update(ctx, pv)).To(Succeed())
+
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: client.ObjectKey{Namespace: pvc.Namespace, Name: pvc.Name}})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: pv.Name}, pv)).To(Succeed())
+		Expect(pv.Spec.PersistentVolumeReclaimPolicy).To(Equal(corev1.PersistentVolumeReclaimRetain))
+		Expect(volrecOwnedFields(pv).reclaimPolicy).To(BeTrue())
+	})
+})
+
+func TestReclaimLabelChanged(t *testing.T) {
+	const label = "storage.k8s.twr.dev/reclaim-policy"
+
+	r := &PersistentVolumeClaimReconciler{startedAt: time.Now()}
+	claim := func(uid string, created time.Time, value string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			UID:               types.UID(uid),
+			CreationTimestamp: metav1.NewTime(created),
+			Labels:            map[string]string{label: value},
+		}}
+	}
+	existing, created := r.startedAt.Add(-time.Hour), r.startedAt.Add(time.Minute)
+
+	steps := []struct {
+		name string
+		pvc  *corev1.PersistentVolumeClaim
+		want bool
+	}{
+		{"existing claim seen at startup", claim("a", existing, "Retain"), false},
+		{"existing claim resynced", claim("a", existing, "Retain"), false},
+		{"existing claim label changed", claim("a", existing, "Delete"), true},
+		{"existing claim resynced after change", claim("a", existing, "Delete"), false},
+		{"claim created after startup", claim("b", created, "Retain"), true},
+		{"new claim resynced", claim("b", created, "Retain"), false},
+	}
+
+	for _, step := range steps {
+		if got := r.reclaimLabelChanged(step.pvc, label); got != step.want {
+			t.Errorf("%s: reclaimLabelChanged() = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
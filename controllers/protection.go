@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"twr.dev/volrec/pkg/reclaim"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch
+
+// protectedBy describes what protects the volume of a claim from a destructive reclaim policy, either the
+// protected label on the claim or a running Pod of a critical StatefulSet mounting it. An empty string is
+// returned when the volume isn't protected.
+func protectedBy(ctx context.Context, c client.Reader, pvc *corev1.PersistentVolumeClaim) (string, error) {
+	if reclaim.IsProtected(pvc) {
+		return fmt.Sprintf("label %s on PVC %s", reclaim.ProtectedLabel, pvc.Name), nil
+	}
+
+	var pods corev1.PodList
+
+	if err := c.List(ctx, &pods, client.InNamespace(pvc.Namespace), client.MatchingFields{PodClaimNameField: pvc.Name}); err != nil {
+		return "", fmt.Errorf("could not list Pods: %+v", err)
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		owner := metav1.GetControllerOf(&pod)
+		if owner == nil || owner.Kind != "StatefulSet" || owner.APIVersion != appsv1.SchemeGroupVersion.String() {
+			continue
+		}
+
+		var sts appsv1.StatefulSet
+		if err := c.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: owner.Name}, &sts); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+
+		if reclaim.IsCritical(&sts) {
+			return fmt.Sprintf("critical StatefulSet %s (Pod %s)", sts.Name, pod.Name), nil
+		}
+	}
+
+	return "", nil
+}
+
+// claimsForPod maps a Pod to the claims it mounts
+func claimsForPod(obj handler.MapObject) []reconcile.Request {
+	pod, ok := obj.Object.(*corev1.Pod)
+	if !ok {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pod.Namespace, Name: volume.PersistentVolumeClaim.ClaimName}})
+		}
+	}
+
+	return requests
+}
+
+// claimsForStatefulSet maps a StatefulSet to the claims mounted by its Pods
+func claimsForStatefulSet(c client.Reader, log logr.Logger) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		var pods corev1.PodList
+
+		if err := c.List(context.Background(), &pods, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+			log.Error(err, "unable to list Pods for StatefulSet", "namespace", obj.Meta.GetNamespace(), "statefulset", obj.Meta.GetName())
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if owner := metav1.GetControllerOf(pod); owner == nil || owner.UID != obj.Meta.GetUID() {
+				continue
+			}
+			requests = append(requests, claimsForPod(handler.MapObject{Meta: pod, Object: pod})...)
+		}
+
+		return requests
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"twr.dev/volrec/pkg/reclaim"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func statefulSet(name string, critical bool) *appsv1.StatefulSet {
+	sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test1", UID: types.UID(name + "-uid")}}
+	if critical {
+		sts.Labels = map[string]string{reclaim.CriticalLabel: "true"}
+	}
+	return sts
+}
+
+func statefulSetPod(name string, sts *appsv1.StatefulSet, phase corev1.PodPhase, claims ...string) *corev1.Pod {
+	controller := true
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test1"},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+	if sts != nil {
+		pod.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+			Kind:       "StatefulSet",
+			Name:       sts.Name,
+			UID:        sts.UID,
+			Controller: &controller,
+		}}
+	}
+	for _, claim := range claims {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name:         claim,
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim}},
+		})
+	}
+	return pod
+}
+
+func TestProtectedBy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	critical, plain := statefulSet("db", true), statefulSet("cache", false)
+
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		objects []runtime.Object
+		want    string
+	}{
+		{"not protected", nil, nil, ""},
+		{"protected label", map[string]string{reclaim.ProtectedLabel: "true"}, nil, "label storage.k8s.twr.dev/protected on PVC data"},
+		{"protected label disabled", map[string]string{reclaim.ProtectedLabel: "false"}, nil, ""},
+		{"running Pod of critical StatefulSet", nil, []runtime.Object{critical, statefulSetPod("db-0", critical, corev1.PodRunning, "data")},
+			"critical StatefulSet db (Pod db-0)"},
+		{"pending Pod of critical StatefulSet", nil, []runtime.Object{critical, statefulSetPod("db-0", critical, corev1.PodPending, "data")},
+			"critical StatefulSet db (Pod db-0)"},
+		{"finished Pod of critical StatefulSet", nil, []runtime.Object{critical, statefulSetPod("db-0", critical, corev1.PodSucceeded, "data")}, ""},
+		{"Pod of StatefulSet that isn't critical", nil, []runtime.Object{plain, statefulSetPod("cache-0", plain, corev1.PodRunning, "data")}, ""},
+		{"Pod without StatefulSet", nil, []runtime.Object{statefulSetPod("standalone", nil, corev1.PodRunning, "data")}, ""},
+		{"Pod of deleted StatefulSet", nil, []runtime.Object{statefulSetPod("db-0", critical, corev1.PodRunning, "data")}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClientWithScheme(scheme, tt.objects...)
+			pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "test1", Labels: tt.labels}}
+
+			got, err := protectedBy(context.Background(), c, pvc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("protectedBy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimsForStatefulSet(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	sts, other := statefulSet("db", true), statefulSet("cache", false)
+	c := fake.NewFakeClientWithScheme(scheme,
+		statefulSetPod("db-0", sts, corev1.PodRunning, "data-db-0", "config"),
+		statefulSetPod("db-1", sts, corev1.PodRunning, "data-db-1"),
+		statefulSetPod("cache-0", other, corev1.PodRunning, "data-cache-0"),
+		statefulSetPod("standalone", nil, corev1.PodRunning, "scratch"),
+	)
+
+	got := claimsForStatefulSet(c, zap.New(zap.UseDevMode(true)))(handler.MapObject{Meta: sts, Object: sts})
+
+	claim := func(name string) reconcile.Request {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "test1", Name: name}}
+	}
+	want := []reconcile.Request{claim("data-db-0"), claim("config"), claim("data-db-1")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("claimsForStatefulSet() = %v, want %v", got, want)
+	}
+}
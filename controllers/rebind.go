@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"twr.dev/volrec/pkg/config"
+	"twr.dev/volrec/pkg/reclaim"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// rebind pre-binds the Released Persistent Volume requested through the rebind annotation to a new claim.
+// The stale claimRef is replaced by a reference to the new claim, and the Kubernetes PV controller completes
+// the binding. Once bound the claim is enqueued again by the PV watch, which applies its reclaim policy.
+func (r *PersistentVolumeClaimReconciler) rebind(ctx context.Context, log logr.Logger, pvc *corev1.PersistentVolumeClaim) (ctrl.Result, error) {
+	var pv corev1.PersistentVolume
+
+	pvName := pvc.GetAnnotations()[reclaim.RebindAnnotation]
+	log = log.WithValues("rebind-from", pvName)
+
+	if err := r.Get(ctx, client.ObjectKey{Name: pvName}, &pv); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("PV requested for rebind doesn't exist")
+			r.Recorder.Eventf(pvc, corev1.EventTypeWarning, EventReasonRebindFailed, "Unable to rebind PV %s: PV doesn't exist", pvName)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	// Already pre-bound, waiting on the Kubernetes PV controller
+	if pv.Spec.ClaimRef != nil && pv.Spec.ClaimRef.UID == pvc.UID {
+		log.Info("PV is already pre-bound to PVC")
+		return ctrl.Result{}, nil
+	}
+
+	locked, lockSource, err := volumeLocked(ctx, r, &pv)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if locked {
+		log.Info("PV is administratively locked, skipping", "pv", pv.Name, "lock-source", lockSource)
+		r.Recorder.Eventf(pvc, corev1.EventTypeWarning, EventReasonRebindFailed, "Unable to rebind PV %s: PV is administratively locked by %s", pv.Name, lockSource)
+		return ctrl.Result{}, nil
+	}
+
+	if err := reclaim.CanRebind(&pv, pvc); err != nil {
+		log.Info("PV can't be rebound", "reason", err.Error())
+		r.Recorder.Eventf(pvc, corev1.EventTypeWarning, EventReasonRebindFailed, "Unable to rebind PV %s: %v", pv.Name, err)
+		return ctrl.Result{}, nil
+	}
+
+	previous := reclaim.GetProvenance(&pv)
+
+	if config.Get().DryRun {
+		log.Info("Dry-run enabled, not pre-binding released PV to PVC", "pv", pv.Name, "previous-pvc", previous.Claim)
+		r.Recorder.Eventf(pvc, corev1.EventTypeNormal, EventReasonVolumeRebound, "Would pre-bind Released PV %s, previously claimed by %s", pv.Name, previous.Claim)
+		return ctrl.Result{}, nil
+	}
+
+	pv.Spec.ClaimRef = &corev1.ObjectReference{
+		Kind:       "PersistentVolumeClaim",
+		APIVersion: corev1.SchemeGroupVersion.String(),
+		Namespace:  pvc.Namespace,
+		Name:       pvc.Name,
+		UID:        pvc.UID,
+	}
+
+	// Update rather than patch, so the claimRef is only replaced if the PV is still Released
+	if err := r.Update(ctx, &pv, client.FieldOwner(FieldManager)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Pre-bound released PV to PVC", "pv", pv.Name, "previous-pvc", previous.Claim)
+	r.Recorder.Eventf(pvc, corev1.EventTypeNormal, EventReasonVolumeRebound, "Pre-bound Released PV %s, previously claimed by %s", pv.Name, previous.Claim)
+	r.Recorder.Eventf(&pv, corev1.EventTypeNormal, EventReasonVolumeRebound, "Pre-bound to PVC %s/%s, previously claimed by %s", pvc.Namespace, pvc.Name, previous.Claim)
+
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	reclaimv1alpha1 "twr.dev/volrec/api/v1alpha1"
+	"twr.dev/volrec/pkg/config"
+	"twr.dev/volrec/pkg/reclaim"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// policyResolution describes the reclaim policy requested for a Persistent Volume and where it came from
+type policyResolution struct {
+	// value is the requested reclaim policy, empty if nothing requests one
+	value string
+	// source describes where the value came from for logs and Events
+	source string
+	// rule is the name of the VolumeReclaimRule the value came from, if any
+	rule string
+}
+
+// reportInvalid records a Warning Event on a cluster scoped object volrec can't evaluate. Every claim runs into it,
+// so it's only recorded once per generation of the object rather than for each claim on every reconcile.
+func (r *PersistentVolumeClaimReconciler) reportInvalid(obj interface {
+	runtime.Object
+	metav1.Object
+}, reason, messageFmt string, args ...interface{}) {
+	if _, reported := r.reportedInvalid.LoadOrStore(fmt.Sprintf("%s/%d", obj.GetUID(), obj.GetGeneration()), true); reported {
+		return
+	}
+	r.Recorder.Eventf(obj, corev1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// resolveReclaimPolicy determines the reclaim policy for the Persistent Volume bound to a claim. In order of
+// precedence the policy comes from the reclaim policy label on the claim, the highest priority matching
+// VolumeReclaimRule, or the default reclaim policy label on the claim's Namespace.
+func (r *PersistentVolumeClaimReconciler) resolveReclaimPolicy(ctx context.Context, cfg config.ControllerConfig, pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim) (policyResolution, error) {
+	if value := pvc.GetLabels()[cfg.ReclaimPolicyLabel]; value != "" {
+		return policyResolution{
+			value:  value,
+			source: fmt.Sprintf("label %s on PVC %s/%s", cfg.ReclaimPolicyLabel, pvc.Namespace, pvc.Name),
+		}, nil
+	}
+
+	var (
+		ns    corev1.Namespace
+		rules reclaimv1alpha1.VolumeReclaimRuleList
+	)
+
+	if err := r.Get(ctx, client.ObjectKey{Name: pvc.Namespace}, &ns); err != nil {
+		return policyResolution{}, err
+	}
+
+	if err := r.List(ctx, &rules); err != nil {
+		return policyResolution{}, err
+	}
+
+	rule, invalid := reclaim.MatchRule(rules.Items, pv, pvc, &ns)
+	for _, skipped := range invalid {
+		r.Log.Error(skipped.Err, "Skipping invalid VolumeReclaimRule", "rule", skipped.Rule.Name, "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name))
+		r.reportInvalid(skipped.Rule, EventReasonInvalidRule, "Skipped until fixed: %v", skipped.Err)
+	}
+	if rule != nil {
+		return policyResolution{
+			value:  string(rule.Spec.ReclaimPolicy),
+			source: fmt.Sprintf("VolumeReclaimRule %s", rule.Name),
+			rule:   rule.Name,
+		}, nil
+	}
+
+	// Fall back to the Namespace default if the PVC doesn't override it and no rule matches
+	if cfg.DefaultReclaimPolicyLabel != "" {
+		if value := ns.GetLabels()[cfg.DefaultReclaimPolicyLabel]; value != "" {
+			return policyResolution{
+				value:  value,
+				source: fmt.Sprintf("label %s on Namespace %s", cfg.DefaultReclaimPolicyLabel, ns.Name),
+			}, nil
+		}
+	}
+
+	return policyResolution{}, nil
+}
+
+// +kubebuilder:rbac:groups=reclaim.storage.k8s.twr.dev,resources=volumereclaimrestrictions,verbs=get;list;watch
+
+// checkRestrictions returns a non-nil denial if a VolumeReclaimRestriction doesn't allow the claim to request
+// the reclaim policy. The error is only set when the restrictions can't be evaluated.
+func (r *PersistentVolumeClaimReconciler) checkRestrictions(ctx context.Context, policy corev1.PersistentVolumeReclaimPolicy, pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim) (denial error, err error) {
+	invalid, denial, err := evaluateRestrictions(ctx, r, policy, pv.Spec.StorageClassName, pvc.Namespace)
+	for _, skipped := range invalid {
+		r.Log.Error(skipped.Err, "Invalid VolumeReclaimRestriction", "restriction", skipped.Restriction.Name, "pvc", fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name))
+		r.reportInvalid(skipped.Restriction, EventReasonInvalidRestriction, "Denying Delete and Recycle until fixed: %v", skipped.Err)
+	}
+
+	return denial, err
+}
+
+// evaluateRestrictions checks the reclaim policy against the VolumeReclaimRestrictions for claims of the
+// StorageClass in the Namespace. A Namespace that no longer exists, ie. the one a released PV was claimed
+// from, is evaluated without labels.
+func evaluateRestrictions(ctx context.Context, c client.Reader, policy corev1.PersistentVolumeReclaimPolicy, storageClassName, namespace string) (invalid []reclaim.InvalidRestriction, denial error, err error) {
+	var restrictions reclaimv1alpha1.VolumeReclaimRestrictionList
+
+	if err := c.List(ctx, &restrictions); err != nil {
+		return nil, nil, err
+	}
+
+	if len(restrictions.Items) == 0 {
+		return nil, nil, nil
+	}
+
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	if err := c.Get(ctx, client.ObjectKey{Name: namespace}, &ns); client.IgnoreNotFound(err) != nil {
+		return nil, nil, err
+	}
+
+	invalid, denial = reclaim.CheckRestrictions(restrictions.Items, policy, storageClassName, &ns)
+	return invalid, denial, nil
+}
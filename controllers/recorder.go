@@ -0,0 +1,63 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"twr.dev/volrec/pkg/config"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dryRunPrefix marks the messages of Events recorded while dry-run is enabled
+const dryRunPrefix = "[dry-run] "
+
+// dryRunRecorder marks the Events recorded while dry-run is enabled, since the changes they describe aren't
+// actually made
+type dryRunRecorder struct {
+	record.EventRecorder
+}
+
+// NewEventRecorder wraps an EventRecorder so Events recorded while dry-run is enabled are marked as such
+func NewEventRecorder(recorder record.EventRecorder) record.EventRecorder {
+	return &dryRunRecorder{EventRecorder: recorder}
+}
+
+func (r *dryRunRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.EventRecorder.Event(object, eventtype, reason, dryRunMessage(message))
+}
+
+func (r *dryRunRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.Eventf(object, eventtype, reason, dryRunMessage(messageFmt), args...)
+}
+
+func (r *dryRunRecorder) PastEventf(object runtime.Object, timestamp metav1.Time, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.PastEventf(object, timestamp, eventtype, reason, dryRunMessage(messageFmt), args...)
+}
+
+func (r *dryRunRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, dryRunMessage(messageFmt), args...)
+}
+
+// dryRunMessage prefixes an Event message when dry-run is enabled
+func dryRunMessage(message string) string {
+	if config.Get().DryRun {
+		return dryRunPrefix + message
+	}
+	return message
+}
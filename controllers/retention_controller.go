@@ -0,0 +1,346 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	reclaimv1alpha1 "twr.dev/volrec/api/v1alpha1"
+	"twr.dev/volrec/pkg/config"
+	"twr.dev/volrec/pkg/metrics"
+	"twr.dev/volrec/pkg/reclaim"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+// RetentionReconciler cleans up released Persistent Volumes once they have been retained for as long as
+// their claim requested
+type RetentionReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// ResyncEvents optionally requeues objects outside of the normal watch, ie. on config reload
+	ResyncEvents <-chan event.GenericEvent
+
+	// expiredInDryRun holds the PV's whose retention expiry was already reported while dry-run is enabled, so
+	// it's only counted and recorded once rather than on every reconcile
+	expiredInDryRun sync.Map
+	// deniedExpiry holds the PV's whose retention expiry was already reported as not allowed by a
+	// VolumeReclaimRestriction, so it's only recorded once rather than on every reconcile
+	deniedExpiry sync.Map
+}
+
+// +kubebuilder:rbac:groups=core,resources=persistentvolumes,verbs=get;list;watch;update;patch;delete
+
+// Reconcile records the retention requested for a bound Persistent Volume, and acts on a released Persistent
+// Volume once its retention expires
+func (r *RetentionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("pv", req.NamespacedName)
+	cfg := config.Get()
+
+	if !cfg.RetentionEnabled {
+		return ctrl.Result{}, nil
+	}
+
+	var pv corev1.PersistentVolume
+
+	if err := r.Get(ctx, req.NamespacedName, &pv); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	locked, lockSource, err := volumeLocked(ctx, r, &pv)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if locked {
+		log.Info("PV is administratively locked, skipping", "lock-source", lockSource)
+		return ctrl.Result{}, nil
+	}
+
+	switch pv.Status.Phase {
+	case corev1.VolumeBound:
+		return r.recordRetention(ctx, log, cfg, &pv)
+	case corev1.VolumeReleased:
+		return r.expireRetention(ctx, log, cfg, &pv)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// recordRetention copies the retention requested by the claim of a bound Persistent Volume, or its Namespace,
+// onto the Persistent Volume so it's still known once the claim is deleted
+func (r *RetentionReconciler) recordRetention(ctx context.Context, log logr.Logger, cfg config.ControllerConfig, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	var (
+		pvc corev1.PersistentVolumeClaim
+		ns  corev1.Namespace
+	)
+
+	if pv.Spec.ClaimRef == nil {
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.Get(ctx, client.ObjectKey{Name: pv.Spec.ClaimRef.Name, Namespace: pv.Spec.ClaimRef.Namespace}, &pvc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	retainFor, retentionSource := pvc.GetLabels()[cfg.RetentionLabel], "PVC label"
+	if retainFor == "" {
+		if err := r.Get(ctx, client.ObjectKey{Name: pvc.Namespace}, &ns); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		retainFor, retentionSource = ns.GetLabels()[cfg.RetentionLabel], "Namespace label"
+	}
+
+	if retainFor != "" {
+		if _, err := reclaim.ParseRetention(retainFor); err != nil {
+			log.Error(err, "Invalid retention label", "retention-source", retentionSource)
+			r.Recorder.Eventf(&pvc, corev1.EventTypeWarning, EventReasonInvalidRetention, "Ignoring %s: %v", retentionSource, err)
+			retainFor = ""
+		}
+	}
+
+	if pv.GetAnnotations()[reclaim.RetainForAnnotation] == retainFor {
+		return ctrl.Result{}, nil
+	}
+
+	log.Info("Recording retention on PV", "retain-for", retainFor, "retention-source", retentionSource)
+
+	base := pv.DeepCopy()
+	if retainFor == "" {
+		delete(pv.Annotations, reclaim.RetainForAnnotation)
+	} else {
+		if pv.Annotations == nil {
+			pv.Annotations = make(map[string]string)
+		}
+		pv.Annotations[reclaim.RetainForAnnotation] = retainFor
+	}
+
+	if _, err := applyPersistentVolume(ctx, r, "Retention", pv, base); err != nil {
+		if isFieldManagerConflict(err) {
+			log.Info("Retention annotation on PV is managed by another field manager, skipping", "reason", err.Error())
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("could not update PV: %+v", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// expireRetention acts on a released Persistent Volume that has been retained for as long as requested, and
+// requeues it for when its retention expires otherwise
+func (r *RetentionReconciler) expireRetention(ctx context.Context, log logr.Logger, cfg config.ControllerConfig, pv *corev1.PersistentVolume) (ctrl.Result, error) {
+	retainFor := pv.GetAnnotations()[reclaim.RetainForAnnotation]
+	if retainFor == "" || pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+		return ctrl.Result{}, nil
+	}
+
+	retention, err := reclaim.ParseRetention(retainFor)
+	if err != nil {
+		log.Error(err, "Invalid retention annotation")
+		r.Recorder.Eventf(pv, corev1.EventTypeWarning, EventReasonInvalidRetention, "Ignoring %s annotation: %v", reclaim.RetainForAnnotation, err)
+		return ctrl.Result{}, nil
+	}
+
+	// The release time is recorded by the PersistentVolume controller, which triggers another reconcile
+	releasedAt := reclaim.GetProvenance(pv).ReleasedAt
+	if releasedAt.IsZero() {
+		log.Info("Release time of PV isn't recorded yet, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	expiresAt := releasedAt.Add(retention)
+	if remaining := time.Until(expiresAt); remaining > 0 {
+		log.Info("PV is retained", "retain-for", retainFor, "expires-at", expiresAt)
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	action := cfg.RetentionAction
+
+	// Either action ends with the volume being deleted, which the Namespace and StorageClass it was claimed
+	// from must allow, the same as a claim requesting Delete through its reclaim policy label
+	provenance := reclaim.GetProvenance(pv)
+	namespace, storageClassName := provenance.Namespace, provenance.StorageClass
+	if namespace == "" && pv.Spec.ClaimRef != nil {
+		namespace = pv.Spec.ClaimRef.Namespace
+	}
+	if storageClassName == "" {
+		storageClassName = pv.Spec.StorageClassName
+	}
+	if namespace != "" {
+		invalid, denial, err := evaluateRestrictions(ctx, r, corev1.PersistentVolumeReclaimDelete, storageClassName, namespace)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		for _, skipped := range invalid {
+			log.Error(skipped.Err, "Invalid VolumeReclaimRestriction", "restriction", skipped.Restriction.Name)
+		}
+		if denial != nil {
+			log.Info("Retention of PV expired, but Delete isn't allowed so leaving it in place", "retain-for", retainFor, "released-at", releasedAt, "reason", denial.Error())
+			if _, reported := r.deniedExpiry.LoadOrStore(fmt.Sprintf("%s/%s", pv.UID, denial), true); !reported {
+				r.Recorder.Eventf(pv, corev1.EventTypeWarning, EventReasonReclaimPolicyNotAllowed, "Retention of %s since release at %s expired, not applying action %q: %v", retainFor, releasedAt.Format(time.RFC3339), action, denial)
+			}
+			return ctrl.Result{}, nil
+		}
+	}
+
+	if cfg.RetentionDryRun || cfg.DryRun {
+		log.Info("Retention of PV expired, dry-run enabled so leaving it in place", "retain-for", retainFor, "released-at", releasedAt, "action", action)
+		if _, reported := r.expiredInDryRun.LoadOrStore(fmt.Sprintf("%s/%s", pv.UID, expiresAt), true); reported {
+			return ctrl.Result{}, nil
+		}
+		metrics.RetentionExpirations.WithLabelValues(action, strconv.FormatBool(true)).Inc()
+		r.Recorder.Eventf(pv, corev1.EventTypeNormal, EventReasonRetentionExpired, "Retention of %s since release at %s expired, dry-run enabled so not applying action %q", retainFor, releasedAt.Format(time.RFC3339), action)
+		return ctrl.Result{}, nil
+	}
+
+	metrics.RetentionExpirations.WithLabelValues(action, strconv.FormatBool(false)).Inc()
+
+	switch action {
+	case config.RetentionActionDelete:
+		log.Info("Retention of PV expired, deleting PV", "retain-for", retainFor, "released-at", releasedAt)
+		if err := r.Delete(ctx, pv); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		r.Recorder.Eventf(pv, corev1.EventTypeNormal, EventReasonRetentionExpired, "Retention of %s since release at %s expired, deleted PV", retainFor, releasedAt.Format(time.RFC3339))
+
+	default:
+		log.Info("Retention of PV expired, setting reclaim policy to Delete", "retain-for", retainFor, "released-at", releasedAt)
+		base := pv.DeepCopy()
+		pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+		change := reclaim.PolicyChange{
+			Source: fmt.Sprintf("retention of %s", retainFor),
+			From:   base.Spec.PersistentVolumeReclaimPolicy,
+			To:     corev1.PersistentVolumeReclaimDelete,
+		}
+		if provenance.Claim != "" {
+			change.Claim = fmt.Sprintf("%s/%s", provenance.Namespace, provenance.Claim)
+		}
+		recordPolicyChange(log, pv, change)
+
+		if _, err := applyPersistentVolume(ctx, r, "Retention", pv, base); err != nil {
+			if !isFieldManagerConflict(err) && apierrors.IsConflict(err) {
+				// The PV changed since it was read, retry with the latest version
+				return ctrl.Result{Requeue: true}, nil
+			}
+			r.Recorder.Eventf(pv, corev1.EventTypeWarning, EventReasonUpdateFailed, "Unable to set reclaim policy to %s after retention expired: %v", corev1.PersistentVolumeReclaimDelete, err)
+			if isFieldManagerConflict(err) {
+				return ctrl.Result{}, nil
+			}
+			return ctrl.Result{}, fmt.Errorf("could not update PV: %+v", err)
+		}
+		metrics.ReclaimPolicyChanges.WithLabelValues(string(base.Spec.PersistentVolumeReclaimPolicy), string(corev1.PersistentVolumeReclaimDelete), pv.Spec.StorageClassName).Inc()
+		r.Recorder.Eventf(pv, corev1.EventTypeNormal, EventReasonRetentionExpired, "Retention of %s since release at %s expired, reclaim policy changed from %s to %s", retainFor, releasedAt.Format(time.RFC3339), base.Spec.PersistentVolumeReclaimPolicy, corev1.PersistentVolumeReclaimDelete)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager adds a Kubernetes controller instance to a Controller Manager
+func (r *RetentionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	b := ctrl.NewControllerManagedBy(mgr).
+		Named("retention").
+		For(&corev1.PersistentVolume{})
+
+	if r.ResyncEvents != nil {
+		b = b.Watches(&source.Channel{Source: r.ResyncEvents}, &handler.EnqueueRequestForObject{})
+	}
+
+	c, err := b.
+		WithEventFilter(predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				// Only bindings, releases, locks, and changes to the recorded release time or retention matter
+				oldPV, oldOK := e.ObjectOld.(*corev1.PersistentVolume)
+				newPV, newOK := e.ObjectNew.(*corev1.PersistentVolume)
+				if !oldOK || !newOK {
+					return false
+				}
+				oldAnnotations, newAnnotations := oldPV.GetAnnotations(), newPV.GetAnnotations()
+				return oldPV.Status.Phase != newPV.Status.Phase ||
+					oldAnnotations[reclaim.ReleasedAtAnnotation] != newAnnotations[reclaim.ReleasedAtAnnotation] ||
+					oldAnnotations[reclaim.RetainForAnnotation] != newAnnotations[reclaim.RetainForAnnotation] ||
+					reclaim.IsLocked(oldPV) != reclaim.IsLocked(newPV)
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+		}).
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	retentionLabelChanged := predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			retentionLabel := config.Get().RetentionLabel
+			return e.MetaOld.GetLabels()[retentionLabel] != e.MetaNew.GetLabels()[retentionLabel]
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+
+	// Enqueue the PV bound to a claim when the retention label of the claim changes
+	if err := c.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: volumesForClaim(r, r.Log)},
+		retentionLabelChanged); err != nil {
+		return err
+	}
+
+	// Enqueue every PV of a StorageClass when the StorageClass is locked or unlocked
+	if err := c.Watch(&source.Kind{Type: &storagev1.StorageClass{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: volumesForStorageClass(r, r.Log)},
+		lockChanged); err != nil {
+		return err
+	}
+
+	// Enqueue every released PV whenever a VolumeReclaimRestriction is created, changed or deleted, so expired
+	// retention that wasn't allowed is acted on once it is
+	if err := c.Watch(&source.Kind{Type: &reclaimv1alpha1.VolumeReclaimRestriction{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: releasedVolumes(r, r.Log)},
+		predicate.GenerationChangedPredicate{}); err != nil {
+		return err
+	}
+
+	// Enqueue every PV bound in a Namespace when the retention label of the Namespace changes
+	return c.Watch(&source.Kind{Type: &corev1.Namespace{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: volumesForNamespace(r, r.Log)},
+		retentionLabelChanged)
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	reclaimv1alpha1 "twr.dev/volrec/api/v1alpha1"
+	"twr.dev/volrec/pkg/config"
+	"twr.dev/volrec/pkg/reclaim"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestExpireRetentionRestricted(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = reclaimv1alpha1.AddToScheme(scheme)
+
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pv-1",
+			Annotations: map[string]string{
+				reclaim.RetainForAnnotation:      "1h",
+				reclaim.ClaimNamespaceAnnotation: "prod1",
+				reclaim.ClaimNameAnnotation:      "data",
+				reclaim.ReleasedAtAnnotation:     time.Now().Add(-2 * time.Hour).Format(time.RFC3339),
+			},
+		},
+		Spec:   corev1.PersistentVolumeSpec{PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain},
+		Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeReleased},
+	}
+	c := fake.NewFakeClientWithScheme(scheme,
+		pv.DeepCopy(),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod1", Labels: map[string]string{"environment": "prod"}}},
+		&reclaimv1alpha1.VolumeReclaimRestriction{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod-retain-only"},
+			Spec: reclaimv1alpha1.VolumeReclaimRestrictionSpec{
+				NamespaceSelector:      &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "prod"}},
+				AllowedReclaimPolicies: []corev1.PersistentVolumeReclaimPolicy{corev1.PersistentVolumeReclaimRetain},
+			},
+		},
+	)
+	recorder := record.NewFakeRecorder(10)
+	r := &RetentionReconciler{Client: c, Log: zap.New(zap.UseDevMode(true)), Recorder: recorder}
+	cfg := config.ControllerConfig{RetentionEnabled: true, RetentionAction: config.RetentionActionPolicy}
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.expireRetention(context.Background(), r.Log, cfg, pv.DeepCopy()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got corev1.PersistentVolume
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "pv-1"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+		t.Errorf("expected reclaim policy to stay %s, got %s", corev1.PersistentVolumeReclaimRetain, got.Spec.PersistentVolumeReclaimPolicy)
+	}
+
+	if len(recorder.Events) != 1 {
+		t.Fatalf("expected a single Event, got %d", len(recorder.Events))
+	}
+	if e := <-recorder.Events; !strings.Contains(e, EventReasonReclaimPolicyNotAllowed) || !strings.Contains(e, "prod-retain-only") {
+		t.Errorf("unexpected Event %q", e)
+	}
+}
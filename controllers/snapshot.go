@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"twr.dev/volrec/pkg/reclaim"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+// snapshotPollInterval is how often a pending VolumeSnapshot is checked for readyToUse
+const snapshotPollInterval = 10 * time.Second
+
+// snapshotErrorInterval is how often a pending VolumeSnapshot that failed is checked again, the snapshot
+// controller may still recover from the error, or an admin may delete the snapshot so it's taken again
+const snapshotErrorInterval = 5 * time.Minute
+
+// volumeSnapshotGVK is the CSI VolumeSnapshot kind, it's handled as unstructured so volrec doesn't depend on
+// the external-snapshotter client, or the VolumeSnapshot CRD's being installed unless snapshots are enabled
+var volumeSnapshotGVK = schema.GroupVersionKind{Group: "snapshot.storage.k8s.io", Version: "v1", Kind: "VolumeSnapshot"}
+
+// +kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create
+
+// snapshotBeforeDelete takes a VolumeSnapshot of a claim before its Persistent Volume is switched to the Delete
+// reclaim policy. The name of the snapshot is returned once it's readyToUse. Otherwise an empty name is
+// returned, along with how long to wait before checking again, or zero if the snapshot can't be taken at all.
+//
+// The name of the snapshot is recorded on the Persistent Volume before the snapshot is created, so a snapshot
+// is never left behind untracked when the controller fails in between. A recorded snapshot that doesn't exist
+// is (re)created under the same name.
+func (r *PersistentVolumeClaimReconciler) snapshotBeforeDelete(ctx context.Context, log logr.Logger, pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim) (string, time.Duration, error) {
+	if pv.Spec.CSI == nil {
+		log.Info("PV isn't a CSI volume and can't be snapshotted", "pv", pv.Name)
+		r.Recorder.Eventf(pvc, corev1.EventTypeWarning, EventReasonSnapshotFailed, "PV %s isn't a CSI volume and can't be snapshotted, the reclaim policy won't be changed to %s", pv.Name, corev1.PersistentVolumeReclaimDelete)
+		return "", 0, nil
+	}
+
+	pending := pv.GetAnnotations()[reclaim.PendingSnapshotAnnotation]
+	if pending == "" {
+		pending = reclaim.NewSnapshotName(pvc.Name)
+
+		base := pv.DeepCopy()
+		if pv.Annotations == nil {
+			pv.Annotations = make(map[string]string)
+		}
+		pv.Annotations[reclaim.PendingSnapshotAnnotation] = pending
+
+		if _, err := applyPersistentVolume(ctx, r, "PersistentVolumeClaim", pv, base); err != nil {
+			return "", 0, fmt.Errorf("could not update PV: %+v", err)
+		}
+	}
+
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+
+	if err := r.Get(ctx, client.ObjectKey{Namespace: pvc.Namespace, Name: pending}, snapshot); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", 0, err
+		}
+		return "", snapshotPollInterval, r.createSnapshot(ctx, log, pending, pv, pvc)
+	}
+
+	ready, message := reclaim.SnapshotStatus(snapshot)
+	if ready {
+		return pending, 0, nil
+	}
+	if message != "" {
+		log.Info("Pending VolumeSnapshot failed", "snapshot", pending, "reason", message)
+		r.Recorder.Eventf(pvc, corev1.EventTypeWarning, EventReasonSnapshotFailed, "VolumeSnapshot %s of PV %s failed, the reclaim policy won't be changed to %s until it's ready, delete the VolumeSnapshot to take it again: %s", pending, pv.Name, corev1.PersistentVolumeReclaimDelete, message)
+		return "", snapshotErrorInterval, nil
+	}
+
+	log.Info("Waiting for VolumeSnapshot to be ready", "snapshot", pending)
+	return "", snapshotPollInterval, nil
+}
+
+// createSnapshot creates the VolumeSnapshot of a claim recorded as pending on its Persistent Volume
+func (r *PersistentVolumeClaimReconciler) createSnapshot(ctx context.Context, log logr.Logger, name string, pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim) error {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	snapshot.SetName(name)
+	snapshot.SetNamespace(pvc.Namespace)
+	if err := unstructured.SetNestedField(snapshot.Object, pvc.Name, "spec", "source", "persistentVolumeClaimName"); err != nil {
+		return err
+	}
+
+	if pv.Spec.StorageClassName != "" {
+		var sc storagev1.StorageClass
+
+		if err := r.Get(ctx, client.ObjectKey{Name: pv.Spec.StorageClassName}, &sc); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if class := sc.GetAnnotations()[reclaim.SnapshotClassAnnotation]; class != "" {
+			if err := unstructured.SetNestedField(snapshot.Object, class, "spec", "volumeSnapshotClassName"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := r.Create(ctx, snapshot); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		r.Recorder.Eventf(pvc, corev1.EventTypeWarning, EventReasonSnapshotFailed, "Unable to create VolumeSnapshot of PV %s, the reclaim policy won't be changed to %s: %v", pv.Name, corev1.PersistentVolumeReclaimDelete, err)
+		return fmt.Errorf("could not create VolumeSnapshot: %+v", err)
+	}
+
+	log.Info("Created VolumeSnapshot before setting reclaim policy to Delete", "pv", pv.Name, "snapshot", name)
+	r.Recorder.Eventf(pvc, corev1.EventTypeNormal, EventReasonSnapshotCreated, "Created VolumeSnapshot %s of PV %s, the reclaim policy will be changed to %s once it's ready", name, pv.Name, corev1.PersistentVolumeReclaimDelete)
+
+	return nil
+}
@@ -20,14 +20,22 @@ import (
 	"flag"
 	"os"
 
+	"github.com/spf13/pflag"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	corev1 "k8s.io/api/core/v1"
+	reclaimv1alpha1 "twr.dev/volrec/api/v1alpha1"
 	"twr.dev/volrec/controllers"
+	"twr.dev/volrec/pkg/dryrun"
+	"twr.dev/volrec/pkg/metrics"
+	"twr.dev/volrec/pkg/version"
+	"twr.dev/volrec/pkg/webhooks"
 
 	c "twr.dev/volrec/pkg/config"
 	// +kubebuilder:scaffold:imports
@@ -42,28 +50,58 @@ func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 
 	_ = corev1.AddToScheme(scheme)
+	_ = reclaimv1alpha1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var enableWebhook bool
+	var configFile string
+	var dryRunReportAddr string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8081", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&configFile, "config", "", "Path to a YAML configuration file (ie. a mounted volrec-config ConfigMap). Flags and environment variables take precedence over the file.")
+	flag.StringVar(&dryRunReportAddr, "dry-run-report-addr", ":8082", "The address the dry-run report endpoint binds to. Set to \"\" to disable it.")
+	flag.BoolVar(&enableWebhook, "enable-webhook", true, "Enable the validating admission webhooks for the reclaim policy label on Persistent Volume Claims")
+	flag.Bool("dry-run", false, "Compute and report the changes to Persistent Volumes without making them")
 	flag.String("reclaim-label", "storage.k8s.twr.dev/reclaim-policy", "The label to use for tracking Persistent Volume reclaim policy")
+	flag.String("default-reclaim-label", "storage.k8s.twr.dev/default-reclaim-policy", "The label on a Namespace to use for the default reclaim policy of Persistent Volumes bound to claims in that Namespace")
+	flag.Bool("authorize-reclaim-changes", false, "Require a SubjectAccessReview for users changing the reclaim policy label on an existing Persistent Volume Claim to Delete or Recycle")
+	flag.Int("reclaim-history-limit", 10, "The number of reclaim policy changes kept in the history annotation on Persistent Volumes, 0 disables the history")
+	flag.Bool("enable-retention", false, "Enable cleanup of released Persistent Volumes once the retention requested through the retention label expires")
+	flag.String("retention-label", "storage.k8s.twr.dev/retain-for", "The label on a Persistent Volume Claim or Namespace to use for how long released Persistent Volumes are retained (ie. 30d)")
+	flag.String("retention-action", "policy", "What to do with an expired Persistent Volume: \"policy\" switches its reclaim policy to Delete, \"delete\" deletes the Persistent Volume object")
+	flag.Bool("retention-dry-run", false, "Only record Events and metrics for expired Persistent Volumes instead of acting on them")
+	flag.Bool("enable-rebind", false, "Enable binding new Persistent Volume Claims to Released Persistent Volumes previously claimed from the same Namespace on request")
+	flag.Bool("snapshot-before-delete", false, "Take a CSI VolumeSnapshot of a Persistent Volume Claim and wait for it to be ready before switching its Persistent Volume to the Delete reclaim policy")
+	flag.String("claim-propagation-prefix", "pvc.k8s.twr.dev/", "The prefix of the Persistent Volume Claim labels and annotations copied to Persistent Volumes")
 	flag.Bool("set-owner", false, "Toggle whether or not owner information from a given namespace is transfered to the Persistent Volume")
 	flag.String("owner-label", "k8s.twr.dev/owner", "The Label to use to set owner information on a Persistent Volume")
 	flag.Bool("set-ns", false, "Toggle whether or not to add a label mapping Persistent Volumes back to a namespace")
 	flag.String("ns-label", "k8s.twr.dev/owning-namespace", "The label to use for identifying an owning namespace on a Persisent Volume")
+	flag.Bool("set-unclaimed", false, "Toggle whether or not to label Persistent Volumes that aren't bound to a claim")
+	flag.String("unclaimed-label", "k8s.twr.dev/unclaimed", "The label to use for identifying Persistent Volumes that aren't bound to a claim")
 
-	flag.Parse()
+	// Lists aren't supported by the flag package
+	pflag.StringSlice("propagate-namespace-labels", nil, "Namespace labels to copy to bound Persistent Volumes, as \"key\", \"key=pv-key\", or \"prefix/*=pv-prefix/*\"")
+	pflag.StringSlice("propagate-namespace-annotations", nil, "Namespace annotations to copy to bound Persistent Volumes, as \"key\", \"key=pv-key\", or \"prefix/*=pv-prefix/*\"")
+	pflag.StringSlice("propagate-claim-labels", nil, "Persistent Volume Claim labels to copy to its Persistent Volume under the claim propagation prefix, as \"key\", \"key=pv-key\", or \"prefix/*=pv-prefix-*\"")
+	pflag.StringSlice("propagate-claim-annotations", nil, "Persistent Volume Claim annotations to copy to its Persistent Volume under the claim propagation prefix, as \"key\", \"key=pv-key\", or \"prefix/*=pv-prefix-*\"")
 
-	c.InitConfig(setupLog)
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
+	pflag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 
+	if err := c.InitConfig(setupLog, pflag.CommandLine, configFile); err != nil {
+		setupLog.Error(err, "unable to initialize config")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -76,32 +114,97 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err = controllers.IndexPersistentVolumes(mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to setup field indexes", "cache", "PersistentVolume")
+		os.Exit(1)
+	}
+	if err = controllers.IndexPods(mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to setup field indexes", "cache", "Pod")
+		os.Exit(1)
+	}
+
+	// Requeue PV's and PVC's outside of their normal watches, ie. when the config file is reloaded
+	pvResync := make(chan event.GenericEvent)
+	pvcResync := make(chan event.GenericEvent)
+	retentionResync := make(chan event.GenericEvent)
+
+	if configFile != "" {
+		c.WatchConfig(ctrl.Log.WithName("config"))
+
+		if err = mgr.Add(&controllers.ConfigResyncer{
+			Client:                      mgr.GetClient(),
+			Log:                         ctrl.Log.WithName("controllers").WithName("ConfigResync"),
+			PersistentVolumeEvents:      pvResync,
+			PersistentVolumeClaimEvents: pvcResync,
+			RetentionEvents:             retentionResync,
+		}); err != nil {
+			setupLog.Error(err, "unable to create config resyncer")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&controllers.PersistentVolumeReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("PersistentVolume"),
-		Scheme: mgr.GetScheme(),
+		Client:       mgr.GetClient(),
+		Log:          ctrl.Log.WithName("controllers").WithName("PersistentVolume"),
+		Scheme:       mgr.GetScheme(),
+		Recorder:     controllers.NewEventRecorder(mgr.GetEventRecorderFor("volrec")),
+		ResyncEvents: pvResync,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PersistentVolume")
 		os.Exit(1)
 	}
 	if err = (&controllers.PersistentVolumeClaimReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("PersistentVolumeClaim"),
-		Scheme: mgr.GetScheme(),
+		Client:       mgr.GetClient(),
+		Log:          ctrl.Log.WithName("controllers").WithName("PersistentVolumeClaim"),
+		Scheme:       mgr.GetScheme(),
+		Recorder:     controllers.NewEventRecorder(mgr.GetEventRecorderFor("volrec")),
+		ResyncEvents: pvcResync,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PersistentVolumeClaim")
 		os.Exit(1)
 	}
-	if err = (&controllers.NamespaceReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Namespace"),
-		Scheme: mgr.GetScheme(),
+	if err = (&controllers.RetentionReconciler{
+		Client:       mgr.GetClient(),
+		Log:          ctrl.Log.WithName("controllers").WithName("Retention"),
+		Scheme:       mgr.GetScheme(),
+		Recorder:     controllers.NewEventRecorder(mgr.GetEventRecorderFor("volrec")),
+		ResyncEvents: retentionResync,
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Namespace")
+		setupLog.Error(err, "unable to create controller", "controller", "Retention")
 		os.Exit(1)
 	}
+	if enableWebhook {
+		if err = (&webhooks.PersistentVolumeClaimValidator{
+			Log: ctrl.Log.WithName("webhooks").WithName("PersistentVolumeClaim"),
+		}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PersistentVolumeClaim")
+			os.Exit(1)
+		}
+		if err = (&webhooks.PersistentVolumeClaimAuthorizer{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("webhooks").WithName("PersistentVolumeClaimAuthorizer"),
+		}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PersistentVolumeClaimAuthorizer")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
+	if dryRunReportAddr != "" {
+		if err = mgr.Add(&dryrun.Server{
+			Addr: dryRunReportAddr,
+			Log:  ctrl.Log.WithName("dry-run"),
+		}); err != nil {
+			setupLog.Error(err, "unable to create dry-run report server")
+			os.Exit(1)
+		}
+	}
+
+	crmetrics.Registry.MustRegister(&metrics.PersistentVolumeCollector{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("metrics"),
+	})
+
 	if _, err := mgr.GetCache().GetInformer(&corev1.Namespace{}); err != nil {
 		setupLog.Error(err, "unable to setup cache", "cache", "Namespace")
 		os.Exit(1)
@@ -115,7 +218,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	setupLog.Info("starting manager")
+	setupLog.Info("starting manager", "version", version.Version)
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
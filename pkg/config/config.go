@@ -17,33 +17,295 @@ limitations under the License.
 package config
 
 import (
-	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/go-logr/logr"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"twr.dev/volrec/pkg/propagation"
+	"twr.dev/volrec/pkg/reclaim"
+)
+
+const (
+	// EnvPrefix is the prefix for environment variables that override configuration file values
+	EnvPrefix = "VOLREC"
+
+	// RetentionActionPolicy switches the reclaim policy of an expired Persistent Volume to Delete
+	RetentionActionPolicy = "policy"
+	// RetentionActionDelete deletes the Persistent Volume object once it expires, leaving the backing storage
+	RetentionActionDelete = "delete"
 )
 
 var (
-	// VolrecConfig holds the controller configuration
-	VolrecConfig ControllerConfig
+	// volrecConfig holds the current controller configuration and is swapped atomically on reload
+	volrecConfig atomic.Value
+
+	// v resolves configuration from flags, environment variables, and the configuration file
+	v = viper.New()
+
+	subscribersMu sync.Mutex
+	subscribers   []chan ControllerConfig
+
+	// flagKeys maps configuration file keys to the command line flags that override them
+	flagKeys = map[string]string{
+		"dry-run":                           "dry-run",
+		"storage.reclaim.label":             "reclaim-label",
+		"storage.reclaim.default-label":     "default-reclaim-label",
+		"storage.reclaim.authorize":         "authorize-reclaim-changes",
+		"storage.reclaim.history-limit":     "reclaim-history-limit",
+		"storage.retention.enabled":         "enable-retention",
+		"storage.retention.label":           "retention-label",
+		"storage.retention.action":          "retention-action",
+		"storage.retention.dry-run":         "retention-dry-run",
+		"storage.rebind.enabled":            "enable-rebind",
+		"storage.snapshot.enabled":          "snapshot-before-delete",
+		"owner.label":                       "owner-label",
+		"owner.set-owner":                   "set-owner",
+		"owner.ns-label":                    "ns-label",
+		"owner.set-ns":                      "set-ns",
+		"owner.unclaimed-label":             "unclaimed-label",
+		"owner.set-unclaimed":               "set-unclaimed",
+		"propagation.namespace-labels":      "propagate-namespace-labels",
+		"propagation.namespace-annotations": "propagate-namespace-annotations",
+		"propagation.claim-labels":          "propagate-claim-labels",
+		"propagation.claim-annotations":     "propagate-claim-annotations",
+		"propagation.claim-prefix":          "claim-propagation-prefix",
+	}
 )
 
+func init() {
+	volrecConfig.Store(ControllerConfig{})
+}
+
 // ControllerConfig represents configuration for the controller
 type ControllerConfig struct {
-	ReclaimPolicyLabel string
-	OwnerLabel         string
-	OwnerSet           bool
-	NsLabel            string
-	NsSet              bool
+	DryRun                    bool
+	ReclaimPolicyLabel        string
+	DefaultReclaimPolicyLabel string
+	AuthorizeReclaimChanges   bool
+	ReclaimHistoryLimit       int
+	RetentionEnabled          bool
+	RetentionLabel            string
+	RetentionAction           string
+	RetentionDryRun           bool
+	RebindEnabled             bool
+	SnapshotBeforeDelete      bool
+	OwnerLabel                string
+	OwnerSet                  bool
+	NsLabel                   string
+	NsSet                     bool
+	UnclaimedLabel            string
+	UnclaimedSet              bool
+	// NamespaceLabels and NamespaceAnnotations are propagation rules (see propagation.ParseRules) for the
+	// Namespace labels and annotations copied to bound Persistent Volumes
+	NamespaceLabels      []string
+	NamespaceAnnotations []string
+	// ClaimLabels and ClaimAnnotations are propagation rules (see propagation.ParsePrefixedRules) for the
+	// claim labels and annotations copied to its Persistent Volume under ClaimPrefix
+	ClaimLabels      []string
+	ClaimAnnotations []string
+	ClaimPrefix      string
 }
 
-// InitConfig initializes the controller configuration
-func InitConfig(setupLog logr.Logger) {
+// Validate checks that the configuration is usable by the controllers
+func (c ControllerConfig) Validate() error {
+	if c.ReclaimPolicyLabel == "" {
+		return fmt.Errorf("reclaim policy label must not be empty")
+	}
+	if c.ReclaimHistoryLimit < 0 {
+		return fmt.Errorf("reclaim history limit must not be negative")
+	}
+	if c.RetentionEnabled && c.RetentionLabel == "" {
+		return fmt.Errorf("retention label must not be empty when retention is enabled")
+	}
+	if c.RetentionEnabled && c.RetentionAction != RetentionActionPolicy && c.RetentionAction != RetentionActionDelete {
+		return fmt.Errorf("invalid retention action %q, must be one of [%s %s]", c.RetentionAction, RetentionActionPolicy, RetentionActionDelete)
+	}
+	if c.OwnerSet && c.OwnerLabel == "" {
+		return fmt.Errorf("owner label must not be empty when set-owner is enabled")
+	}
+	if c.NsSet && c.NsLabel == "" {
+		return fmt.Errorf("namespace label must not be empty when set-ns is enabled")
+	}
+	if c.UnclaimedSet && c.UnclaimedLabel == "" {
+		return fmt.Errorf("unclaimed label must not be empty when set-unclaimed is enabled")
+	}
+	namespaceLabelRules, err := propagation.ParseRules(c.NamespaceLabels)
+	if err != nil {
+		return fmt.Errorf("invalid namespace label propagation: %v", err)
+	}
+	// Propagated labels would be overwritten by, or overwrite, the labels volrec sets itself
+	for _, rule := range namespaceLabelRules {
+		for _, key := range []string{c.OwnerLabel, c.NsLabel, c.UnclaimedLabel} {
+			if key != "" && rule.Produces(key) {
+				return fmt.Errorf("invalid namespace label propagation, target %q of rule for %q must not be used by %q", rule.Target, rule.Source, key)
+			}
+		}
+	}
+	if _, err := propagation.ParseRules(c.NamespaceAnnotations); err != nil {
+		return fmt.Errorf("invalid namespace annotation propagation: %v", err)
+	}
+	if c.ClaimPrefix != "" {
+		// Keys under the claim prefix are removed once they aren't copied from the claim anymore, even when
+		// propagation is disabled, so the prefix can't hold any of the labels and annotations volrec sets itself
+		if !strings.HasSuffix(c.ClaimPrefix, "/") {
+			return fmt.Errorf("claim propagation prefix %q must end with \"/\"", c.ClaimPrefix)
+		}
+		for _, key := range []string{c.OwnerLabel, c.NsLabel, c.UnclaimedLabel, reclaim.LockedAnnotation} {
+			if strings.HasPrefix(key, c.ClaimPrefix) {
+				return fmt.Errorf("claim propagation prefix %q must not be used by %q", c.ClaimPrefix, key)
+			}
+		}
+	}
+	if _, err := propagation.ParsePrefixedRules(c.ClaimLabels, c.ClaimPrefix); err != nil {
+		return fmt.Errorf("invalid claim label propagation: %v", err)
+	}
+	if _, err := propagation.ParsePrefixedRules(c.ClaimAnnotations, c.ClaimPrefix); err != nil {
+		return fmt.Errorf("invalid claim annotation propagation: %v", err)
+	}
+
+	return nil
+}
+
+// Get returns the current controller configuration
+func Get() ControllerConfig {
+	return volrecConfig.Load().(ControllerConfig)
+}
+
+// Set atomically replaces the current controller configuration
+func Set(c ControllerConfig) {
+	volrecConfig.Store(c)
+}
+
+// Subscribe returns a channel that receives the new controller configuration each time it is reloaded.
+// Reloads are coalesced if the receiver falls behind, so only the latest configuration is guaranteed
+// to be delivered.
+func Subscribe() <-chan ControllerConfig {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+
+	ch := make(chan ControllerConfig, 1)
+	subscribers = append(subscribers, ch)
+
+	return ch
+}
+
+// InitConfig initializes the controller configuration.
+//
+// Values are resolved in the following order of precedence (highest first):
+//  1. Flags explicitly set on the command line
+//  2. Environment variables (ie. VOLREC_OWNER_SET_OWNER for "owner.set-owner")
+//  3. The configuration file passed via "--config"
+//  4. Flag default values
+func InitConfig(setupLog logr.Logger, flags *pflag.FlagSet, configFile string) error {
+	for key, name := range flagKeys {
+		if err := v.BindPFlag(key, flags.Lookup(name)); err != nil {
+			return fmt.Errorf("unable to bind flag %q: %v", name, err)
+		}
+	}
+
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	v.AutomaticEnv()
+
+	if configFile != "" {
+		// ConfigMap keys are mounted as files without an extension, so the type can't be inferred
+		v.SetConfigFile(configFile)
+		v.SetConfigType("yaml")
+
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("unable to read config file %q: %v", configFile, err)
+		}
+		setupLog.Info("Loaded config file", "config", v.ConfigFileUsed())
+	}
 
 	// Initialize the config to be used everywhere
-	VolrecConfig.ReclaimPolicyLabel = flag.Lookup("reclaim-label").Value.(flag.Getter).Get().(string)
-	VolrecConfig.OwnerLabel = flag.Lookup("owner-label").Value.(flag.Getter).Get().(string)
-	VolrecConfig.OwnerSet = flag.Lookup("set-owner").Value.(flag.Getter).Get().(bool)
-	VolrecConfig.NsLabel = flag.Lookup("ns-label").Value.(flag.Getter).Get().(string)
-	VolrecConfig.NsSet = flag.Lookup("set-ns").Value.(flag.Getter).Get().(bool)
+	cfg := load()
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	Set(cfg)
+
+	setupLog.Info("Initialized config", "config", cfg)
+
+	return nil
+}
+
+// WatchConfig watches the configuration file for changes (including ConfigMap updates propagated by
+// the kubelet), swaps in the reloaded configuration, and notifies subscribers. Invalid configuration
+// is logged and ignored, leaving the previous configuration in place.
+func WatchConfig(log logr.Logger) {
+	v.OnConfigChange(func(e fsnotify.Event) {
+		cfg := load()
+		if err := cfg.Validate(); err != nil {
+			log.Error(err, "Ignoring invalid config", "config", v.ConfigFileUsed())
+			return
+		}
+
+		if reflect.DeepEqual(cfg, Get()) {
+			return
+		}
+
+		Set(cfg)
+		log.Info("Reloaded config", "config", cfg)
+
+		subscribersMu.Lock()
+		defer subscribersMu.Unlock()
+
+		for _, ch := range subscribers {
+			select {
+			case ch <- cfg:
+			default:
+				// Drop the stale pending reload so the latest config is always delivered
+				select {
+				case <-ch:
+				default:
+				}
+				ch <- cfg
+			}
+		}
+	})
+	v.WatchConfig()
+}
+
+// load builds a controller configuration from the current viper state
+func load() ControllerConfig {
+	return ControllerConfig{
+		DryRun:                    v.GetBool("dry-run"),
+		ReclaimPolicyLabel:        v.GetString("storage.reclaim.label"),
+		DefaultReclaimPolicyLabel: v.GetString("storage.reclaim.default-label"),
+		AuthorizeReclaimChanges:   v.GetBool("storage.reclaim.authorize"),
+		ReclaimHistoryLimit:       v.GetInt("storage.reclaim.history-limit"),
+		RetentionEnabled:          v.GetBool("storage.retention.enabled"),
+		RetentionLabel:            v.GetString("storage.retention.label"),
+		RetentionAction:           v.GetString("storage.retention.action"),
+		RetentionDryRun:           v.GetBool("storage.retention.dry-run"),
+		RebindEnabled:             v.GetBool("storage.rebind.enabled"),
+		SnapshotBeforeDelete:      v.GetBool("storage.snapshot.enabled"),
+		OwnerLabel:                v.GetString("owner.label"),
+		OwnerSet:                  v.GetBool("owner.set-owner"),
+		NsLabel:                   v.GetString("owner.ns-label"),
+		NsSet:                     v.GetBool("owner.set-ns"),
+		UnclaimedLabel:            v.GetString("owner.unclaimed-label"),
+		UnclaimedSet:              v.GetBool("owner.set-unclaimed"),
+		NamespaceLabels:           getStringSlice("propagation.namespace-labels"),
+		NamespaceAnnotations:      getStringSlice("propagation.namespace-annotations"),
+		ClaimLabels:               getStringSlice("propagation.claim-labels"),
+		ClaimAnnotations:          getStringSlice("propagation.claim-annotations"),
+		ClaimPrefix:               v.GetString("propagation.claim-prefix"),
+	}
+}
 
+// getStringSlice reads a list from the current viper state. Environment variables hold a comma separated
+// list, which viper would otherwise split on whitespace.
+func getStringSlice(key string) []string {
+	if value, ok := v.Get(key).(string); ok {
+		return strings.Split(value, ",")
+	}
+	return v.GetStringSlice(key)
 }
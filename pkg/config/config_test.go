@@ -0,0 +1,172 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func testFlags() *pflag.FlagSet {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.Bool("dry-run", false, "")
+	fs.String("reclaim-label", "storage.k8s.twr.dev/reclaim-policy", "")
+	fs.String("default-reclaim-label", "storage.k8s.twr.dev/default-reclaim-policy", "")
+	fs.Bool("authorize-reclaim-changes", false, "")
+	fs.Int("reclaim-history-limit", 10, "")
+	fs.Bool("enable-retention", false, "")
+	fs.String("retention-label", "storage.k8s.twr.dev/retain-for", "")
+	fs.String("retention-action", "policy", "")
+	fs.Bool("retention-dry-run", false, "")
+	fs.Bool("enable-rebind", false, "")
+	fs.Bool("snapshot-before-delete", false, "")
+	fs.Bool("set-owner", false, "")
+	fs.String("owner-label", "k8s.twr.dev/owner", "")
+	fs.Bool("set-ns", false, "")
+	fs.String("ns-label", "k8s.twr.dev/owning-namespace", "")
+	fs.Bool("set-unclaimed", false, "")
+	fs.String("unclaimed-label", "k8s.twr.dev/unclaimed", "")
+	fs.StringSlice("propagate-namespace-labels", nil, "")
+	fs.StringSlice("propagate-namespace-annotations", nil, "")
+	fs.StringSlice("propagate-claim-labels", nil, "")
+	fs.StringSlice("propagate-claim-annotations", nil, "")
+	fs.String("claim-propagation-prefix", "pvc.k8s.twr.dev/", "")
+
+	return fs
+}
+
+func TestInitConfigAndReload(t *testing.T) {
+	log := zap.New(zap.UseDevMode(true))
+
+	dir, err := ioutil.TempDir("", "volrec-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(configFile, []byte("owner:\n  set-owner: true\n  label: team\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Explicitly set flags take precedence over the config file
+	fs := testFlags()
+	if err := fs.Parse([]string{"--owner-label=cost-center"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitConfig(log, fs, configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Get()
+	if !cfg.OwnerSet || cfg.OwnerLabel != "cost-center" || cfg.ReclaimPolicyLabel != "storage.k8s.twr.dev/reclaim-policy" {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+
+	reloads := Subscribe()
+	WatchConfig(log)
+
+	if err := ioutil.WriteFile(configFile, []byte("owner:\n  set-owner: true\n  set-ns: true\npropagation:\n  namespace-labels:\n  - environment\n  - team.example.com/*=k8s.twr.dev/team-*\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case cfg = <-reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for config reload")
+	}
+
+	if !cfg.NsSet || !reflect.DeepEqual(Get(), cfg) {
+		t.Fatalf("unexpected reloaded config %+v", cfg)
+	}
+	if want := []string{"environment", "team.example.com/*=k8s.twr.dev/team-*"}; !reflect.DeepEqual(cfg.NamespaceLabels, want) {
+		t.Fatalf("NamespaceLabels = %v, want %v", cfg.NamespaceLabels, want)
+	}
+}
+
+func TestValidateNamespacePropagation(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  []string
+		wantErr bool
+	}{
+		{"no rules", nil, false},
+		{"copied as is", []string{"cost-center"}, false},
+		{"renamed", []string{"cost-center=k8s.twr.dev/cost-center"}, false},
+		{"owner label target", []string{"team=k8s.twr.dev/owner"}, true},
+		{"namespace label target", []string{"k8s.twr.dev/owning-namespace"}, true},
+		{"prefix covering unclaimed label", []string{"team.example.com/*=k8s.twr.dev/*"}, true},
+		{"invalid target", []string{"cost-center=-cost-center"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ControllerConfig{
+				ReclaimPolicyLabel: "storage.k8s.twr.dev/reclaim-policy",
+				OwnerLabel:         "k8s.twr.dev/owner",
+				NsLabel:            "k8s.twr.dev/owning-namespace",
+				UnclaimedLabel:     "k8s.twr.dev/unclaimed",
+				NamespaceLabels:    tt.labels,
+			}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateClaimPropagation(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		labels  []string
+		wantErr bool
+	}{
+		{"dedicated prefix", "pvc.k8s.twr.dev/", []string{"app"}, false},
+		{"no rules", "", nil, false},
+		{"no rules with owner label prefix", "k8s.twr.dev/", nil, true},
+		{"rules without prefix", "", []string{"app"}, true},
+		{"missing slash", "pvc.k8s.twr.dev", []string{"app"}, true},
+		{"owner label prefix", "k8s.twr.dev/", []string{"app"}, true},
+		{"volrec annotation prefix", "storage.k8s.twr.dev/", []string{"app"}, true},
+		{"invalid rule", "pvc.k8s.twr.dev/", []string{"app=example.com/app"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ControllerConfig{
+				ReclaimPolicyLabel: "storage.k8s.twr.dev/reclaim-policy",
+				OwnerLabel:         "k8s.twr.dev/owner",
+				NsLabel:            "k8s.twr.dev/owning-namespace",
+				UnclaimedLabel:     "k8s.twr.dev/unclaimed",
+				ClaimLabels:        tt.labels,
+				ClaimPrefix:        tt.prefix,
+			}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
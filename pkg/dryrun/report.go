@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	reportMu sync.Mutex
+	// report holds the latest change each controller would make to each Persistent Volume
+	report = map[reportKey]Change{}
+)
+
+// reportKey identifies the change a controller would make to a Persistent Volume
+type reportKey struct {
+	persistentVolume string
+	controller       string
+}
+
+// Change describes the change a controller would make to a Persistent Volume if dry-run weren't enabled
+type Change struct {
+	PersistentVolume   string               `json:"persistentVolume"`
+	Controller         string               `json:"controller"`
+	Labels             map[string]string    `json:"labels,omitempty"`
+	RemovedLabels      []string             `json:"removedLabels,omitempty"`
+	Annotations        map[string]string    `json:"annotations,omitempty"`
+	RemovedAnnotations []string             `json:"removedAnnotations,omitempty"`
+	ReclaimPolicy      *ReclaimPolicyChange `json:"reclaimPolicy,omitempty"`
+	Time               time.Time            `json:"time"`
+}
+
+// ReclaimPolicyChange describes a change of reclaim policy
+type ReclaimPolicyChange struct {
+	From corev1.PersistentVolumeReclaimPolicy `json:"from"`
+	To   corev1.PersistentVolumeReclaimPolicy `json:"to"`
+}
+
+// NewChange describes the changes made to pv since base by a controller
+func NewChange(controller string, pv, base *corev1.PersistentVolume) Change {
+	change := Change{
+		PersistentVolume: pv.Name,
+		Controller:       controller,
+		Time:             time.Now(),
+	}
+
+	change.Labels, change.RemovedLabels = diff(base.GetLabels(), pv.GetLabels())
+	change.Annotations, change.RemovedAnnotations = diff(base.GetAnnotations(), pv.GetAnnotations())
+
+	if base.Spec.PersistentVolumeReclaimPolicy != pv.Spec.PersistentVolumeReclaimPolicy {
+		change.ReclaimPolicy = &ReclaimPolicyChange{
+			From: base.Spec.PersistentVolumeReclaimPolicy,
+			To:   pv.Spec.PersistentVolumeReclaimPolicy,
+		}
+	}
+
+	return change
+}
+
+// diff returns the keys set to a new value in current, and the keys removed from base
+func diff(base, current map[string]string) (map[string]string, []string) {
+	var (
+		set     map[string]string
+		removed []string
+	)
+
+	for key, value := range current {
+		if old, ok := base[key]; !ok || old != value {
+			if set == nil {
+				set = make(map[string]string)
+			}
+			set[key] = value
+		}
+	}
+
+	for key := range base {
+		if _, ok := current[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+
+	return set, removed
+}
+
+// Record adds a change to the report, replacing the previous change of the same controller to the same
+// Persistent Volume
+func Record(change Change) {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+
+	report[reportKey{change.PersistentVolume, change.Controller}] = change
+}
+
+// Forget removes the change of a controller to a Persistent Volume from the report, once there's nothing left
+// to change
+func Forget(persistentVolume, controller string) {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+
+	delete(report, reportKey{persistentVolume, controller})
+}
+
+// Changes returns the changes in the report, sorted by Persistent Volume and controller
+func Changes() []Change {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+
+	changes := make([]Change, 0, len(report))
+	for _, change := range report {
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].PersistentVolume != changes[j].PersistentVolume {
+			return changes[i].PersistentVolume < changes[j].PersistentVolume
+		}
+		return changes[i].Controller < changes[j].Controller
+	})
+
+	return changes
+}
+
+// Handler serves the report as JSON
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(Changes()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNewChangeAndReport(t *testing.T) {
+	base := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pv1",
+			Labels:      map[string]string{"k8s.twr.dev/owner": "team-a", "k8s.twr.dev/unclaimed": "true"},
+			Annotations: map[string]string{"storage.k8s.twr.dev/claim-name": "data"},
+		},
+		Spec: corev1.PersistentVolumeSpec{PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete},
+	}
+	pv := base.DeepCopy()
+	pv.Labels["k8s.twr.dev/owner"] = "team-b"
+	delete(pv.Labels, "k8s.twr.dev/unclaimed")
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimRetain
+
+	change := NewChange("PersistentVolumeClaim", pv, base)
+
+	if want := map[string]string{"k8s.twr.dev/owner": "team-b"}; !reflect.DeepEqual(change.Labels, want) {
+		t.Errorf("Labels = %v, want %v", change.Labels, want)
+	}
+	if want := []string{"k8s.twr.dev/unclaimed"}; !reflect.DeepEqual(change.RemovedLabels, want) {
+		t.Errorf("RemovedLabels = %v, want %v", change.RemovedLabels, want)
+	}
+	if change.Annotations != nil || change.RemovedAnnotations != nil {
+		t.Errorf("Annotations = %v, RemovedAnnotations = %v, want none", change.Annotations, change.RemovedAnnotations)
+	}
+	if want := (&ReclaimPolicyChange{From: corev1.PersistentVolumeReclaimDelete, To: corev1.PersistentVolumeReclaimRetain}); !reflect.DeepEqual(change.ReclaimPolicy, want) {
+		t.Errorf("ReclaimPolicy = %v, want %v", change.ReclaimPolicy, want)
+	}
+
+	Record(change)
+	Record(NewChange("PersistentVolume", pv, base))
+	if got := len(Changes()); got != 2 {
+		t.Fatalf("len(Changes()) = %d, want 2", got)
+	}
+
+	Forget("pv1", "PersistentVolume")
+	Forget("pv1", "PersistentVolumeClaim")
+	if got := len(Changes()); got != 0 {
+		t.Errorf("len(Changes()) = %d after Forget, want 0", got)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dryrun
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/go-logr/logr"
+)
+
+// ReportPath is the path the dry-run report is served on
+const ReportPath = "/dry-run"
+
+// Server serves the dry-run report over HTTP, it's a Runnable added to the Controller Manager
+type Server struct {
+	Addr string
+	Log  logr.Logger
+}
+
+// Start serves the report until stop is closed
+func (s *Server) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+	mux.Handle(ReportPath, Handler())
+
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: mux}
+	go func() {
+		<-stop
+		if err := server.Shutdown(context.Background()); err != nil {
+			s.Log.Error(err, "unable to shutdown dry-run report server")
+		}
+	}()
+
+	s.Log.Info("Serving dry-run report", "addr", s.Addr, "path", ReportPath)
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"twr.dev/volrec/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	pvsByReclaimPolicyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "persistent_volumes"),
+		"Number of Persistent Volumes by current reclaim policy",
+		[]string{"reclaim_policy"}, nil,
+	)
+
+	pvsByOwnerDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "persistent_volumes_by_owner"),
+		"Number of Persistent Volumes by the value of the owner label",
+		[]string{"owner"}, nil,
+	)
+)
+
+// PersistentVolumeCollector reports gauges for the current population of Persistent Volumes. The
+// gauges are computed from the informer cache at scrape time so they never drift from the cluster.
+type PersistentVolumeCollector struct {
+	Client client.Reader
+	Log    logr.Logger
+}
+
+// Describe implements prometheus.Collector
+func (c *PersistentVolumeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pvsByReclaimPolicyDesc
+	ch <- pvsByOwnerDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *PersistentVolumeCollector) Collect(ch chan<- prometheus.Metric) {
+	var pvs corev1.PersistentVolumeList
+
+	if err := c.Client.List(context.Background(), &pvs); err != nil {
+		c.Log.Error(err, "unable to list PV's for metrics")
+		return
+	}
+
+	ownerLabel := config.Get().OwnerLabel
+	byPolicy := map[string]float64{}
+	byOwner := map[string]float64{}
+
+	for _, pv := range pvs.Items {
+		byPolicy[string(pv.Spec.PersistentVolumeReclaimPolicy)]++
+		if owner := pv.GetLabels()[ownerLabel]; owner != "" {
+			byOwner[owner]++
+		}
+	}
+
+	for policy, count := range byPolicy {
+		ch <- prometheus.MustNewConstMetric(pvsByReclaimPolicyDesc, prometheus.GaugeValue, count, policy)
+	}
+	for owner, count := range byOwner {
+		ch <- prometheus.MustNewConstMetric(pvsByOwnerDesc, prometheus.GaugeValue, count, owner)
+	}
+}
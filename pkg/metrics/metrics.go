@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	namespace = "volrec"
+)
+
+var (
+	// ReclaimPolicyChanges counts reclaim policy changes applied to Persistent Volumes
+	ReclaimPolicyChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reclaim_policy_changes_total",
+		Help:      "Number of reclaim policy changes applied to Persistent Volumes",
+	}, []string{"from", "to", "storage_class"})
+
+	// InvalidReclaimPolicies counts reclaim policy label values that were rejected
+	InvalidReclaimPolicies = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "invalid_reclaim_policy_total",
+		Help:      "Number of invalid reclaim policy label values found on Persistent Volume Claims",
+	}, []string{"namespace"})
+
+	// UpdateConflicts counts Persistent Volume updates that failed with a conflict
+	UpdateConflicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pv_update_conflicts_total",
+		Help:      "Number of Persistent Volume updates that failed with a conflict",
+	}, []string{"controller"})
+
+	// NoopReconciles counts reconciles where the Persistent Volume already matched and no API call was made
+	NoopReconciles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "noop_reconciles_total",
+		Help:      "Number of reconciles that skipped updating a Persistent Volume because nothing changed",
+	}, []string{"controller"})
+
+	// RetentionExpirations counts released Persistent Volumes whose retention expired
+	RetentionExpirations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retention_expirations_total",
+		Help:      "Number of released Persistent Volumes whose retention expired",
+	}, []string{"action", "dry_run"})
+
+	// DryRunChanges counts Persistent Volume changes skipped because dry-run is enabled
+	DryRunChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dry_run_changes_total",
+		Help:      "Number of Persistent Volume changes that would have been made if dry-run weren't enabled",
+	}, []string{"controller"})
+
+	// UpdateDuration observes the latency of Persistent Volume updates
+	UpdateDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pv_update_duration_seconds",
+		Help:      "Latency of Persistent Volume updates",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"controller"})
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(
+		ReclaimPolicyChanges,
+		InvalidReclaimPolicies,
+		UpdateConflicts,
+		NoopReconciles,
+		RetentionExpirations,
+		DryRunChanges,
+		UpdateDuration,
+	)
+}
+
+// ObserveUpdate records the latency of a Persistent Volume update started at start, and counts it as a
+// conflict if err is a conflict error
+func ObserveUpdate(controller string, start time.Time, err error) {
+	UpdateDuration.WithLabelValues(controller).Observe(time.Since(start).Seconds())
+
+	if apierrors.IsConflict(err) {
+		UpdateConflicts.WithLabelValues(controller).Inc()
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package propagation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Wildcard marks a rule as matching every key with the given prefix
+const Wildcard = "*"
+
+// Rule copies a label or annotation from a source object to a target object, optionally renaming it.
+// Prefix rules copy every key starting with Source, replacing the prefix with Target.
+type Rule struct {
+	Source string
+	Target string
+	Prefix bool
+}
+
+// ParseRules parses rules in the "source[=target]" format, ie. "cost-center",
+// "cost-center=k8s.twr.dev/cost-center", or "team.example.com/*=k8s.twr.dev/team-*" for prefixes.
+// Keys are copied as is when no target is given.
+func ParseRules(specs []string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(specs))
+
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		source, target := spec, spec
+		if i := strings.Index(spec, "="); i >= 0 {
+			source, target = strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+		}
+
+		rule := Rule{Source: source, Target: target}
+		if strings.HasSuffix(source, Wildcard) {
+			if !strings.HasSuffix(target, Wildcard) {
+				return nil, fmt.Errorf("invalid rule %q, the target of a prefix rule must end with %q", spec, Wildcard)
+			}
+			rule = Rule{Source: strings.TrimSuffix(source, Wildcard), Target: strings.TrimSuffix(target, Wildcard), Prefix: true}
+		} else if strings.HasSuffix(target, Wildcard) {
+			return nil, fmt.Errorf("invalid rule %q, only prefix rules can have a target ending with %q", spec, Wildcard)
+		}
+
+		if rule.Source == "" || rule.Target == "" {
+			return nil, fmt.Errorf("invalid rule %q, the source and target must not be empty", spec)
+		}
+		if strings.Contains(rule.Source, Wildcard) || strings.Contains(rule.Target, Wildcard) {
+			return nil, fmt.Errorf("invalid rule %q, %q is only supported at the end of a key", spec, Wildcard)
+		}
+		if err := validateTarget(spec, rule); err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// ParsePrefixedRules parses rules like ParseRules, but copies keys under prefix, ie. "pvc.k8s.twr.dev/", so
+// they're set apart from the other keys on the target. Keys are copied without their own prefix when no target
+// is given, ie. "app.kubernetes.io/name" is copied to "pvc.k8s.twr.dev/name".
+func ParsePrefixedRules(specs []string, prefix string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(specs))
+
+	for _, spec := range specs {
+		parsed, err := ParseRules([]string{spec})
+		if err != nil {
+			return nil, err
+		}
+		if len(parsed) == 0 {
+			continue
+		}
+		if prefix == "" {
+			return nil, fmt.Errorf("invalid rule %q, the prefix must not be empty", spec)
+		}
+
+		rule := parsed[0]
+		if !strings.Contains(spec, "=") {
+			rule.Target = rule.Target[strings.LastIndex(rule.Target, "/")+1:]
+		}
+		rule.Target = prefix + rule.Target
+		if err := validateTarget(spec, rule); err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// validateTarget checks that the keys a rule copies to are valid label and annotation keys. Prefix rules are
+// checked with a placeholder name, since the rest of the key comes from the source.
+func validateTarget(spec string, rule Rule) error {
+	key := rule.Target
+	if rule.Prefix {
+		key += "x"
+	}
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return fmt.Errorf("invalid rule %q, target %q isn't a valid key: %s", spec, rule.Target, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// targetKey returns the key a source key is copied to, and whether the rule matches the source key at all
+func (r Rule) targetKey(key string) (string, bool) {
+	if !r.Prefix {
+		return r.Target, key == r.Source
+	}
+	if !strings.HasPrefix(key, r.Source) || key == r.Source {
+		return "", false
+	}
+	return r.Target + strings.TrimPrefix(key, r.Source), true
+}
+
+// Produces reports whether a target key can be the result of the rule
+func (r Rule) Produces(key string) bool {
+	if !r.Prefix {
+		return key == r.Target
+	}
+	return strings.HasPrefix(key, r.Target) && key != r.Target
+}
+
+// Apply returns the target keys and values the rules copy from source. When several source keys map to the
+// same target key, the first rule wins, and the lowest source key within a prefix rule.
+func Apply(rules []Rule, source map[string]string) map[string]string {
+	keys := make([]string, 0, len(source))
+	for key := range source {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make(map[string]string)
+	for _, rule := range rules {
+		for _, key := range keys {
+			target, ok := rule.targetKey(key)
+			if !ok {
+				continue
+			}
+			if _, ok := result[target]; ok {
+				continue
+			}
+			result[target] = source[key]
+		}
+	}
+
+	return result
+}
+
+// Sync updates target with the keys and values the rules copy from source. Keys produced by the rules that
+// are no longer found on source are removed from target when owned reports they were set by a previous sync,
+// so keys managed by someone else are left alone. The keys set and removed are returned.
+func Sync(rules []Rule, source map[string]string, target map[string]string, owned func(key string) bool) (set, removed []string) {
+	desired := Apply(rules, source)
+
+	for key, value := range desired {
+		if current, ok := target[key]; ok && current == value {
+			continue
+		}
+		target[key] = value
+		set = append(set, key)
+	}
+
+	for key := range target {
+		if _, ok := desired[key]; ok || !owned(key) {
+			continue
+		}
+		for _, rule := range rules {
+			if rule.Produces(key) {
+				delete(target, key)
+				removed = append(removed, key)
+				break
+			}
+		}
+	}
+
+	sort.Strings(set)
+	sort.Strings(removed)
+
+	return set, removed
+}
+
+// Prune removes the keys starting with prefix that aren't in keep from target when owned reports they were set
+// by a previous sync, ie. once the rule that copied them is removed. The keys removed are returned.
+func Prune(target, keep map[string]string, prefix string, owned func(key string) bool) []string {
+	var removed []string
+
+	for key := range target {
+		if _, ok := keep[key]; ok || !strings.HasPrefix(key, prefix) || !owned(key) {
+			continue
+		}
+		delete(target, key)
+		removed = append(removed, key)
+	}
+	sort.Strings(removed)
+
+	return removed
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package propagation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []Rule
+		wantErr bool
+	}{
+		{"cost-center", []Rule{{Source: "cost-center", Target: "cost-center"}}, false},
+		{"cost-center=k8s.twr.dev/cost-center", []Rule{{Source: "cost-center", Target: "k8s.twr.dev/cost-center"}}, false},
+		{"team.example.com/*", []Rule{{Source: "team.example.com/", Target: "team.example.com/", Prefix: true}}, false},
+		{"team.example.com/*=k8s.twr.dev/team-*", []Rule{{Source: "team.example.com/", Target: "k8s.twr.dev/team-", Prefix: true}}, false},
+		{"", []Rule{}, false},
+		{"team.example.com/*=team", nil, true},
+		{"team=team-*", nil, true},
+		{"*", nil, true},
+		{"=team", nil, true},
+		{"te*am", nil, true},
+		{"team=-team", nil, true},
+		{"team=example.com/team/name", nil, true},
+		{"team.example.com/*=example.com/team/*", nil, true},
+		{"team.example.com/*=-*", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseRules([]string{tt.spec})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRules(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRules(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	rules, err := ParseRules([]string{"cost-center=k8s.twr.dev/cost-center", "team.example.com/*=k8s.twr.dev/team-*", "environment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	owned := func(key string) bool {
+		return key != "environment"
+	}
+
+	tests := []struct {
+		name        string
+		source      map[string]string
+		target      map[string]string
+		want        map[string]string
+		wantSet     []string
+		wantRemoved []string
+	}{
+		{
+			name:    "copied and renamed",
+			source:  map[string]string{"cost-center": "1234", "team.example.com/name": "storage", "unrelated": "x"},
+			target:  map[string]string{},
+			want:    map[string]string{"k8s.twr.dev/cost-center": "1234", "k8s.twr.dev/team-name": "storage"},
+			wantSet: []string{"k8s.twr.dev/cost-center", "k8s.twr.dev/team-name"},
+		},
+		{
+			name:   "already in sync",
+			source: map[string]string{"cost-center": "1234"},
+			target: map[string]string{"k8s.twr.dev/cost-center": "1234"},
+			want:   map[string]string{"k8s.twr.dev/cost-center": "1234"},
+		},
+		{
+			name:        "removed from source",
+			source:      map[string]string{"team.example.com/name": "storage"},
+			target:      map[string]string{"k8s.twr.dev/cost-center": "1234", "k8s.twr.dev/team-name": "storage", "k8s.twr.dev/team-lead": "jane", "app": "db"},
+			want:        map[string]string{"k8s.twr.dev/team-name": "storage", "app": "db"},
+			wantRemoved: []string{"k8s.twr.dev/cost-center", "k8s.twr.dev/team-lead"},
+		},
+		{
+			name:   "not owned",
+			source: map[string]string{},
+			target: map[string]string{"environment": "prod"},
+			want:   map[string]string{"environment": "prod"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, removed := Sync(rules, tt.source, tt.target, owned)
+			if !reflect.DeepEqual(tt.target, tt.want) {
+				t.Errorf("Sync() target = %v, want %v", tt.target, tt.want)
+			}
+			if !reflect.DeepEqual(set, tt.wantSet) {
+				t.Errorf("Sync() set = %v, want %v", set, tt.wantSet)
+			}
+			if !reflect.DeepEqual(removed, tt.wantRemoved) {
+				t.Errorf("Sync() removed = %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestApplyFirstRuleWins(t *testing.T) {
+	rules, err := ParseRules([]string{"team=k8s.twr.dev/team", "example.com/*=k8s.twr.dev/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := Apply(rules, map[string]string{"team": "a", "example.com/team": "b"})
+	if want := map[string]string{"k8s.twr.dev/team": "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Apply() = %v, want %v", got, want)
+	}
+}
+
+func TestParsePrefixedRules(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []Rule
+		wantErr bool
+	}{
+		{"backup-tier", []Rule{{Source: "backup-tier", Target: "pvc.k8s.twr.dev/backup-tier"}}, false},
+		{"app.kubernetes.io/name", []Rule{{Source: "app.kubernetes.io/name", Target: "pvc.k8s.twr.dev/name"}}, false},
+		{"app.kubernetes.io/name=app", []Rule{{Source: "app.kubernetes.io/name", Target: "pvc.k8s.twr.dev/app"}}, false},
+		{"backup.example.com/*", []Rule{{Source: "backup.example.com/", Target: "pvc.k8s.twr.dev/", Prefix: true}}, false},
+		{"backup.example.com/*=backup-*", []Rule{{Source: "backup.example.com/", Target: "pvc.k8s.twr.dev/backup-", Prefix: true}}, false},
+		{"app=example.com/app", nil, true},
+		{"app=-app", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParsePrefixedRules([]string{tt.spec}, "pvc.k8s.twr.dev/")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePrefixedRules(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePrefixedRules(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParsePrefixedRules([]string{"app"}, ""); err == nil {
+		t.Error("ParsePrefixedRules() with an empty prefix didn't fail")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	target := map[string]string{"pvc.k8s.twr.dev/app": "db", "pvc.k8s.twr.dev/tier": "gold", "pvc.k8s.twr.dev/manual": "x", "app": "db"}
+	owned := func(key string) bool {
+		return key != "pvc.k8s.twr.dev/manual"
+	}
+
+	removed := Prune(target, map[string]string{"pvc.k8s.twr.dev/app": "db"}, "pvc.k8s.twr.dev/", owned)
+	if want := []string{"pvc.k8s.twr.dev/tier"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("Prune() = %v, want %v", removed, want)
+	}
+	if want := map[string]string{"pvc.k8s.twr.dev/app": "db", "pvc.k8s.twr.dev/manual": "x", "app": "db"}; !reflect.DeepEqual(target, want) {
+		t.Errorf("Prune() target = %v, want %v", target, want)
+	}
+}
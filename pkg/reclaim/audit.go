@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"encoding/json"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// PolicyClaimAnnotation records the claim that requested the last reclaim policy change on a Persistent Volume
+	PolicyClaimAnnotation = "storage.k8s.twr.dev/policy-claim"
+	// PolicySourceAnnotation records what requested the last reclaim policy change, ie. the claim label or a rule
+	PolicySourceAnnotation = "storage.k8s.twr.dev/policy-source"
+	// PreviousPolicyAnnotation records the reclaim policy of a Persistent Volume before the last change
+	PreviousPolicyAnnotation = "storage.k8s.twr.dev/previous-policy"
+	// AppliedPolicyAnnotation records the reclaim policy set by the last change
+	AppliedPolicyAnnotation = "storage.k8s.twr.dev/applied-policy"
+	// PolicyChangedAtAnnotation records when the last reclaim policy change was made, in RFC3339 format
+	PolicyChangedAtAnnotation = "storage.k8s.twr.dev/policy-changed-at"
+	// PolicyChangedByAnnotation records the controller and version that made the last reclaim policy change
+	PolicyChangedByAnnotation = "storage.k8s.twr.dev/policy-changed-by"
+	// PolicyHistoryAnnotation records the last reclaim policy changes as a JSON list, oldest first
+	PolicyHistoryAnnotation = "storage.k8s.twr.dev/policy-history"
+)
+
+// PolicyChange describes a reclaim policy change made on a Persistent Volume
+type PolicyChange struct {
+	// Claim is the namespace/name of the claim the change was made for, or was last bound to if the change was
+	// made after it was released
+	Claim      string                               `json:"claim,omitempty"`
+	Source     string                               `json:"source,omitempty"`
+	From       corev1.PersistentVolumeReclaimPolicy `json:"from"`
+	To         corev1.PersistentVolumeReclaimPolicy `json:"to"`
+	Time       time.Time                            `json:"time"`
+	Controller string                               `json:"controller"`
+}
+
+// GetPolicyHistory reads the reclaim policy changes recorded on an object, oldest first
+func GetPolicyHistory(obj metav1.Object) ([]PolicyChange, error) {
+	value, ok := obj.GetAnnotations()[PolicyHistoryAnnotation]
+	if !ok || value == "" {
+		return nil, nil
+	}
+
+	var history []PolicyChange
+	if err := json.Unmarshal([]byte(value), &history); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// RecordPolicyChange writes the audit annotations for a reclaim policy change on an object and appends it
+// to the history, keeping only the last limit changes. A limit of 0 removes the history. A history that
+// can't be parsed (ie. edited by hand) is replaced, returning the parse error so it can be reported.
+func RecordPolicyChange(obj metav1.Object, change PolicyChange, limit int) error {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	change.Time = change.Time.UTC().Truncate(time.Second)
+
+	for key, value := range map[string]string{
+		PolicyClaimAnnotation:     change.Claim,
+		PolicySourceAnnotation:    change.Source,
+		PreviousPolicyAnnotation:  string(change.From),
+		AppliedPolicyAnnotation:   string(change.To),
+		PolicyChangedAtAnnotation: change.Time.Format(time.RFC3339),
+		PolicyChangedByAnnotation: change.Controller,
+	} {
+		if value == "" {
+			delete(annotations, key)
+			continue
+		}
+		annotations[key] = value
+	}
+
+	history, parseErr := GetPolicyHistory(obj)
+	history = append(history, change)
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+
+	if len(history) == 0 {
+		delete(annotations, PolicyHistoryAnnotation)
+	} else {
+		value, err := json.Marshal(history)
+		if err != nil {
+			return err
+		}
+		annotations[PolicyHistoryAnnotation] = string(value)
+	}
+
+	obj.SetAnnotations(annotations)
+	return parseErr
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecordPolicyChange(t *testing.T) {
+	changedAt := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	change := func(i int) PolicyChange {
+		return PolicyChange{
+			Claim:      "test1/data",
+			Source:     "PVC label",
+			From:       corev1.PersistentVolumeReclaimRetain,
+			To:         corev1.PersistentVolumeReclaimDelete,
+			Time:       changedAt.Add(time.Duration(i) * time.Minute),
+			Controller: "volrec/dev",
+		}
+	}
+
+	tests := []struct {
+		name        string
+		history     string
+		limit       int
+		wantHistory []PolicyChange
+		wantErr     bool
+	}{
+		{
+			name:        "first change",
+			limit:       3,
+			wantHistory: []PolicyChange{change(3)},
+		},
+		{
+			name:        "appended to history",
+			history:     `[{"claim":"test1/data","source":"PVC label","from":"Retain","to":"Delete","time":"2021-03-01T12:02:00Z","controller":"volrec/dev"}]`,
+			limit:       3,
+			wantHistory: []PolicyChange{change(2), change(3)},
+		},
+		{
+			name:        "oldest changes dropped",
+			history:     `[{"claim":"test1/data","source":"PVC label","from":"Retain","to":"Delete","time":"2021-03-01T12:01:00Z","controller":"volrec/dev"},{"claim":"test1/data","source":"PVC label","from":"Retain","to":"Delete","time":"2021-03-01T12:02:00Z","controller":"volrec/dev"}]`,
+			limit:       2,
+			wantHistory: []PolicyChange{change(2), change(3)},
+		},
+		{
+			name:    "history disabled",
+			history: `[{"claim":"test1/data","source":"PVC label","from":"Retain","to":"Delete","time":"2021-03-01T12:02:00Z","controller":"volrec/dev"}]`,
+			limit:   0,
+		},
+		{
+			name:        "invalid history replaced",
+			history:     `not json`,
+			limit:       3,
+			wantHistory: []PolicyChange{change(3)},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pv := &corev1.PersistentVolume{}
+			if tt.history != "" {
+				pv.Annotations = map[string]string{PolicyHistoryAnnotation: tt.history}
+			}
+
+			if err := RecordPolicyChange(pv, change(3), tt.limit); (err != nil) != tt.wantErr {
+				t.Fatalf("RecordPolicyChange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := pv.Annotations[AppliedPolicyAnnotation]; got != "Delete" {
+				t.Errorf("%s = %q, want %q", AppliedPolicyAnnotation, got, "Delete")
+			}
+			if got := pv.Annotations[PolicyChangedAtAnnotation]; got != "2021-03-01T12:03:00Z" {
+				t.Errorf("%s = %q, want %q", PolicyChangedAtAnnotation, got, "2021-03-01T12:03:00Z")
+			}
+
+			history, err := GetPolicyHistory(pv)
+			if err != nil {
+				t.Fatalf("GetPolicyHistory() error = %v", err)
+			}
+			if len(history) != len(tt.wantHistory) {
+				t.Fatalf("GetPolicyHistory() = %+v, want %+v", history, tt.wantHistory)
+			}
+			for i := range history {
+				if !history[i].Time.Equal(tt.wantHistory[i].Time) || history[i].To != tt.wantHistory[i].To {
+					t.Errorf("GetPolicyHistory()[%d] = %+v, want %+v", i, history[i], tt.wantHistory[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRecordPolicyChangeWithoutClaim(t *testing.T) {
+	pv := &corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{PolicyClaimAnnotation: "test1/data"}}}
+
+	if err := RecordPolicyChange(pv, PolicyChange{From: corev1.PersistentVolumeReclaimRetain, To: corev1.PersistentVolumeReclaimDelete, Time: time.Now()}, 1); err != nil {
+		t.Fatalf("RecordPolicyChange() error = %v", err)
+	}
+	if _, ok := pv.Annotations[PolicyClaimAnnotation]; ok {
+		t.Errorf("annotation %s wasn't removed", PolicyClaimAnnotation)
+	}
+}
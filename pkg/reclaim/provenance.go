@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ClaimNamespaceAnnotation records the Namespace of the claim a Persistent Volume was bound to
+	ClaimNamespaceAnnotation = "storage.k8s.twr.dev/claim-namespace"
+	// ClaimNameAnnotation records the name of the claim a Persistent Volume was bound to
+	ClaimNameAnnotation = "storage.k8s.twr.dev/claim-name"
+	// ClaimOwnerAnnotation records the owner of the Namespace a Persistent Volume was claimed from
+	ClaimOwnerAnnotation = "storage.k8s.twr.dev/claim-owner"
+	// ClaimStorageClassAnnotation records the StorageClass a Persistent Volume was claimed with
+	ClaimStorageClassAnnotation = "storage.k8s.twr.dev/claim-storage-class"
+	// ReleasedAtAnnotation records when a Persistent Volume was released from its claim, in RFC3339 format
+	ReleasedAtAnnotation = "storage.k8s.twr.dev/released-at"
+)
+
+// Provenance describes the claim a Persistent Volume was bound to, so it stays attributable after the
+// claim and its Namespace are deleted
+type Provenance struct {
+	Namespace    string
+	Claim        string
+	Owner        string
+	StorageClass string
+	// ReleasedAt is zero while the Persistent Volume is still bound
+	ReleasedAt time.Time
+}
+
+// GetProvenance reads the provenance annotations from an object, an unparsable release timestamp is
+// treated as unset
+func GetProvenance(obj metav1.Object) Provenance {
+	annotations := obj.GetAnnotations()
+
+	p := Provenance{
+		Namespace:    annotations[ClaimNamespaceAnnotation],
+		Claim:        annotations[ClaimNameAnnotation],
+		Owner:        annotations[ClaimOwnerAnnotation],
+		StorageClass: annotations[ClaimStorageClassAnnotation],
+	}
+	if releasedAt, err := time.Parse(time.RFC3339, annotations[ReleasedAtAnnotation]); err == nil {
+		p.ReleasedAt = releasedAt
+	}
+
+	return p
+}
+
+// SetProvenance writes the provenance annotations on an object, empty fields remove their annotation
+func SetProvenance(obj metav1.Object, p Provenance) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	releasedAt := ""
+	if !p.ReleasedAt.IsZero() {
+		releasedAt = p.ReleasedAt.UTC().Format(time.RFC3339)
+	}
+
+	for key, value := range map[string]string{
+		ClaimNamespaceAnnotation:    p.Namespace,
+		ClaimNameAnnotation:         p.Claim,
+		ClaimOwnerAnnotation:        p.Owner,
+		ClaimStorageClassAnnotation: p.StorageClass,
+		ReleasedAtAnnotation:        releasedAt,
+	} {
+		if value == "" {
+			delete(annotations, key)
+			continue
+		}
+		annotations[key] = value
+	}
+
+	obj.SetAnnotations(annotations)
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestProvenance(t *testing.T) {
+	pv := &corev1.PersistentVolume{}
+
+	released := Provenance{
+		Namespace:    "test1",
+		Claim:        "data",
+		Owner:        "team-a",
+		StorageClass: "fast-ssd",
+		ReleasedAt:   time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	SetProvenance(pv, released)
+
+	if got := GetProvenance(pv); got != released {
+		t.Errorf("GetProvenance() = %+v, want %+v", got, released)
+	}
+	if got := pv.Annotations[ReleasedAtAnnotation]; got != "2021-03-01T12:00:00Z" {
+		t.Errorf("%s = %q, want %q", ReleasedAtAnnotation, got, "2021-03-01T12:00:00Z")
+	}
+
+	// Rebinding clears the release timestamp and the owner of a Namespace without one
+	SetProvenance(pv, Provenance{Namespace: "test2", Claim: "data", StorageClass: "fast-ssd"})
+
+	for _, key := range []string{ClaimOwnerAnnotation, ReleasedAtAnnotation} {
+		if _, ok := pv.Annotations[key]; ok {
+			t.Errorf("annotation %s wasn't removed", key)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// RebindAnnotation requests that a new Persistent Volume Claim is bound to a Released Persistent Volume
+	// previously claimed from the same Namespace, the value is the name of the Persistent Volume
+	RebindAnnotation = "storage.k8s.twr.dev/rebind-from"
+)
+
+// CanRebind checks that a Released Persistent Volume can be bound to a new Persistent Volume Claim. The claim
+// must request the Persistent Volume through spec.volumeName, so dynamic provisioning doesn't race the rebind.
+// The Persistent Volume must have been claimed from the Namespace of the new claim according to its provenance,
+// and must satisfy the claim the same way the Kubernetes PV controller checks a pre-bound volume.
+func CanRebind(pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim) error {
+	if pv.Status.Phase != corev1.VolumeReleased {
+		return fmt.Errorf("PV %s is %s, only Released PV's can be rebound", pv.Name, pv.Status.Phase)
+	}
+
+	if pvc.Spec.VolumeName != pv.Name {
+		return fmt.Errorf("PVC must set spec.volumeName to %s", pv.Name)
+	}
+
+	provenance := GetProvenance(pv)
+	if provenance.Namespace == "" {
+		return fmt.Errorf("PV %s has no recorded provenance", pv.Name)
+	}
+	if provenance.Namespace != pvc.Namespace {
+		return fmt.Errorf("PV %s was not claimed from Namespace %s", pv.Name, pvc.Namespace)
+	}
+
+	storageClassName := ""
+	if pvc.Spec.StorageClassName != nil {
+		storageClassName = *pvc.Spec.StorageClassName
+	}
+	if storageClassName != pv.Spec.StorageClassName {
+		return fmt.Errorf("PV %s has StorageClass %q, but the PVC requests %q", pv.Name, pv.Spec.StorageClassName, storageClassName)
+	}
+
+	requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	capacity := pv.Spec.Capacity[corev1.ResourceStorage]
+	if capacity.Cmp(requested) < 0 {
+		return fmt.Errorf("PV %s has a capacity of %s, but the PVC requests %s", pv.Name, capacity.String(), requested.String())
+	}
+
+	for _, mode := range pvc.Spec.AccessModes {
+		if !hasAccessMode(pv.Spec.AccessModes, mode) {
+			return fmt.Errorf("PV %s doesn't support access mode %s", pv.Name, mode)
+		}
+	}
+
+	if volumeMode(pvc.Spec.VolumeMode) != volumeMode(pv.Spec.VolumeMode) {
+		return fmt.Errorf("PV %s has volume mode %s, but the PVC requests %s", pv.Name, volumeMode(pv.Spec.VolumeMode), volumeMode(pvc.Spec.VolumeMode))
+	}
+
+	return nil
+}
+
+// volumeMode returns the volume mode, defaulting to Filesystem when unset
+func volumeMode(mode *corev1.PersistentVolumeMode) corev1.PersistentVolumeMode {
+	if mode == nil {
+		return corev1.PersistentVolumeFilesystem
+	}
+	return *mode
+}
+
+// hasAccessMode reports whether mode is one of modes
+func hasAccessMode(modes []corev1.PersistentVolumeAccessMode, mode corev1.PersistentVolumeAccessMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCanRebind(t *testing.T) {
+	released := func(mutate func(*corev1.PersistentVolume)) *corev1.PersistentVolume {
+		pv := &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "pv1",
+				Annotations: map[string]string{ClaimNamespaceAnnotation: "test1"},
+			},
+			Spec: corev1.PersistentVolumeSpec{
+				Capacity:         corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+				AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				StorageClassName: "fast-ssd",
+			},
+			Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeReleased},
+		}
+		if mutate != nil {
+			mutate(pv)
+		}
+		return pv
+	}
+
+	storageClassName := "fast-ssd"
+	block, filesystem := corev1.PersistentVolumeBlock, corev1.PersistentVolumeFilesystem
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "test1"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClassName,
+			VolumeName:       "pv1",
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("5Gi")},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		pv      *corev1.PersistentVolume
+		wantErr bool
+	}{
+		{"released in same namespace", released(nil), false},
+		{"still bound", released(func(pv *corev1.PersistentVolume) { pv.Status.Phase = corev1.VolumeBound }), true},
+		{"no provenance", released(func(pv *corev1.PersistentVolume) { pv.Annotations = nil }), true},
+		{"other namespace", released(func(pv *corev1.PersistentVolume) { pv.Annotations[ClaimNamespaceAnnotation] = "test2" }), true},
+		{"storage class mismatch", released(func(pv *corev1.PersistentVolume) { pv.Spec.StorageClassName = "slow-hdd" }), true},
+		{"too small", released(func(pv *corev1.PersistentVolume) {
+			pv.Spec.Capacity[corev1.ResourceStorage] = resource.MustParse("1Gi")
+		}), true},
+		{"access mode mismatch", released(func(pv *corev1.PersistentVolume) {
+			pv.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadOnlyMany}
+		}), true},
+		{"block volume for filesystem claim", released(func(pv *corev1.PersistentVolume) { pv.Spec.VolumeMode = &block }), true},
+		{"explicit filesystem volume", released(func(pv *corev1.PersistentVolume) { pv.Spec.VolumeMode = &filesystem }), false},
+		{"not requested by volumeName", released(func(pv *corev1.PersistentVolume) { pv.Name = "pv2" }), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CanRebind(tt.pv, pvc); (err != nil) != tt.wantErr {
+				t.Errorf("CanRebind() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
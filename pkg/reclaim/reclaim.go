@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"fmt"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// LockedAnnotation marks a Persistent Volume, or every Persistent Volume of a StorageClass, as
+	// administratively locked so volrec never modifies it
+	LockedAnnotation = "storage.k8s.twr.dev/locked"
+	// ProtectedLabel marks a Persistent Volume Claim as protected so its volume never gets a destructive
+	// reclaim policy
+	ProtectedLabel = "storage.k8s.twr.dev/protected"
+	// CriticalLabel marks a StatefulSet as critical so the volumes of claims mounted by its Pods never get a
+	// destructive reclaim policy
+	CriticalLabel = "storage.k8s.twr.dev/critical"
+)
+
+// ValidPolicies lists the Reclaim Policies that can be requested through the reclaim policy label
+var ValidPolicies = []corev1.PersistentVolumeReclaimPolicy{
+	corev1.PersistentVolumeReclaimRetain,
+	corev1.PersistentVolumeReclaimDelete,
+	corev1.PersistentVolumeReclaimRecycle,
+}
+
+// ParsePolicy converts a label value into a Persistent Volume Reclaim Policy, returning an error
+// if the value is not one of the supported policies
+func ParsePolicy(value string) (corev1.PersistentVolumeReclaimPolicy, error) {
+	for _, policy := range ValidPolicies {
+		if string(policy) == value {
+			return policy, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid reclaim policy %q, must be one of %v", value, ValidPolicies)
+}
+
+// IsLocked reports whether an object (a Persistent Volume or StorageClass) carries the lock annotation
+// marking it as administratively locked
+func IsLocked(obj metav1.Object) bool {
+	locked, _ := strconv.ParseBool(obj.GetAnnotations()[LockedAnnotation])
+	return locked
+}
+
+// IsDestructive reports whether a reclaim policy can result in the loss of data once the claim is deleted
+func IsDestructive(policy corev1.PersistentVolumeReclaimPolicy) bool {
+	return policy == corev1.PersistentVolumeReclaimDelete || policy == corev1.PersistentVolumeReclaimRecycle
+}
+
+// IsProtected reports whether a Persistent Volume Claim carries the protected label
+func IsProtected(obj metav1.Object) bool {
+	protected, _ := strconv.ParseBool(obj.GetLabels()[ProtectedLabel])
+	return protected
+}
+
+// IsCritical reports whether a workload (ie. a StatefulSet) carries the critical label
+func IsCritical(obj metav1.Object) bool {
+	critical, _ := strconv.ParseBool(obj.GetLabels()[CriticalLabel])
+	return critical
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"fmt"
+	"strings"
+
+	reclaimv1alpha1 "twr.dev/volrec/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// InvalidRestriction is a VolumeReclaimRestriction that can't be evaluated, ie. because of an invalid selector
+type InvalidRestriction struct {
+	Restriction *reclaimv1alpha1.VolumeReclaimRestriction
+	Err         error
+}
+
+// CheckRestrictions returns an error describing the blocking restriction if a claim for the given
+// StorageClass in the given Namespace may not request the reclaim policy. Every matching restriction
+// must allow the policy. Restrictions that can't be evaluated are returned separately so the admin can be told
+// about them, and deny destructive policies since it's unknown whether they'd allow them.
+func CheckRestrictions(restrictions []reclaimv1alpha1.VolumeReclaimRestriction, policy corev1.PersistentVolumeReclaimPolicy, storageClassName string, ns *corev1.Namespace) (invalid []InvalidRestriction, denial error) {
+	for i := range restrictions {
+		restriction := &restrictions[i]
+
+		if !storageClassMatches(restriction.Spec.StorageClassNames, storageClassName) {
+			continue
+		}
+
+		ok, err := selectorMatches(restriction.Spec.NamespaceSelector, ns.GetLabels())
+		if err != nil {
+			invalid = append(invalid, InvalidRestriction{Restriction: restriction, Err: err})
+			if IsDestructive(policy) {
+				return invalid, fmt.Errorf("reclaim policy %s is not allowed for Namespace %s while VolumeReclaimRestriction %s can't be evaluated: %v",
+					policy, ns.Name, restriction.Name, err)
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if !policyAllowed(restriction.Spec.AllowedReclaimPolicies, policy) {
+			return invalid, fmt.Errorf("reclaim policy %s is not allowed for Namespace %s by VolumeReclaimRestriction %s, allowed policies are %s",
+				policy, ns.Name, restriction.Name, joinPolicies(restriction.Spec.AllowedReclaimPolicies))
+		}
+	}
+
+	return invalid, nil
+}
+
+// policyAllowed reports whether the policy is in the allowed set
+func policyAllowed(allowed []corev1.PersistentVolumeReclaimPolicy, policy corev1.PersistentVolumeReclaimPolicy) bool {
+	for _, p := range allowed {
+		if p == policy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// joinPolicies formats a set of policies for messages
+func joinPolicies(policies []corev1.PersistentVolumeReclaimPolicy) string {
+	names := make([]string, len(policies))
+	for i, p := range policies {
+		names[i] = string(p)
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	reclaimv1alpha1 "twr.dev/volrec/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func restriction(name string, spec reclaimv1alpha1.VolumeReclaimRestrictionSpec) reclaimv1alpha1.VolumeReclaimRestriction {
+	return reclaimv1alpha1.VolumeReclaimRestriction{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: spec}
+}
+
+func TestCheckRestrictions(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"environment": "prod"}}}
+
+	prod := &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "prod"}}
+	dev := &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "dev"}}
+
+	invalid := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "environment", Operator: "Within"}}}
+
+	retainOnly := []corev1.PersistentVolumeReclaimPolicy{corev1.PersistentVolumeReclaimRetain}
+	retainOrDelete := []corev1.PersistentVolumeReclaimPolicy{corev1.PersistentVolumeReclaimRetain, corev1.PersistentVolumeReclaimDelete}
+
+	tests := []struct {
+		name         string
+		restrictions []reclaimv1alpha1.VolumeReclaimRestriction
+		policy       corev1.PersistentVolumeReclaimPolicy
+		wantDenied   string
+		wantInvalid  []string
+	}{
+		{"no restrictions", nil, corev1.PersistentVolumeReclaimDelete, "", nil},
+		{"allowed", []reclaimv1alpha1.VolumeReclaimRestriction{
+			restriction("prod", reclaimv1alpha1.VolumeReclaimRestrictionSpec{NamespaceSelector: prod, AllowedReclaimPolicies: retainOnly}),
+		}, corev1.PersistentVolumeReclaimRetain, "", nil},
+		{"denied", []reclaimv1alpha1.VolumeReclaimRestriction{
+			restriction("prod", reclaimv1alpha1.VolumeReclaimRestrictionSpec{NamespaceSelector: prod, AllowedReclaimPolicies: retainOnly}),
+		}, corev1.PersistentVolumeReclaimDelete,
+			"reclaim policy Delete is not allowed for Namespace payments by VolumeReclaimRestriction prod, allowed policies are Retain", nil},
+		{"namespace selector mismatch", []reclaimv1alpha1.VolumeReclaimRestriction{
+			restriction("dev", reclaimv1alpha1.VolumeReclaimRestrictionSpec{NamespaceSelector: dev, AllowedReclaimPolicies: retainOnly}),
+		}, corev1.PersistentVolumeReclaimDelete, "", nil},
+		{"storage class mismatch", []reclaimv1alpha1.VolumeReclaimRestriction{
+			restriction("slow", reclaimv1alpha1.VolumeReclaimRestrictionSpec{StorageClassNames: []string{"slow-hdd"}, AllowedReclaimPolicies: retainOnly}),
+		}, corev1.PersistentVolumeReclaimDelete, "", nil},
+		{"storage class match", []reclaimv1alpha1.VolumeReclaimRestriction{
+			restriction("fast", reclaimv1alpha1.VolumeReclaimRestrictionSpec{StorageClassNames: []string{"slow-hdd", "fast-ssd"}, AllowedReclaimPolicies: retainOnly}),
+		}, corev1.PersistentVolumeReclaimDelete,
+			"reclaim policy Delete is not allowed for Namespace payments by VolumeReclaimRestriction fast, allowed policies are Retain", nil},
+		{"every match must allow", []reclaimv1alpha1.VolumeReclaimRestriction{
+			restriction("cluster", reclaimv1alpha1.VolumeReclaimRestrictionSpec{AllowedReclaimPolicies: retainOrDelete}),
+			restriction("prod", reclaimv1alpha1.VolumeReclaimRestrictionSpec{NamespaceSelector: prod, AllowedReclaimPolicies: retainOnly}),
+		}, corev1.PersistentVolumeReclaimDelete,
+			"reclaim policy Delete is not allowed for Namespace payments by VolumeReclaimRestriction prod, allowed policies are Retain", nil},
+		{"invalid selector denies destructive policy", []reclaimv1alpha1.VolumeReclaimRestriction{
+			restriction("broken", reclaimv1alpha1.VolumeReclaimRestrictionSpec{NamespaceSelector: invalid, AllowedReclaimPolicies: retainOrDelete}),
+		}, corev1.PersistentVolumeReclaimDelete,
+			"reclaim policy Delete is not allowed for Namespace payments while VolumeReclaimRestriction broken can't be evaluated: \"Within\" is not a valid pod selector operator", []string{"broken"}},
+		{"invalid selector skipped for Retain", []reclaimv1alpha1.VolumeReclaimRestriction{
+			restriction("broken", reclaimv1alpha1.VolumeReclaimRestrictionSpec{NamespaceSelector: invalid, AllowedReclaimPolicies: retainOnly}),
+		}, corev1.PersistentVolumeReclaimRetain, "", []string{"broken"}},
+		{"invalid selector of other StorageClass ignored", []reclaimv1alpha1.VolumeReclaimRestriction{
+			restriction("broken", reclaimv1alpha1.VolumeReclaimRestrictionSpec{StorageClassNames: []string{"slow-hdd"}, NamespaceSelector: invalid, AllowedReclaimPolicies: retainOnly}),
+		}, corev1.PersistentVolumeReclaimDelete, "", nil},
+		{"invalid selector doesn't hide denial", []reclaimv1alpha1.VolumeReclaimRestriction{
+			restriction("broken", reclaimv1alpha1.VolumeReclaimRestrictionSpec{NamespaceSelector: invalid, AllowedReclaimPolicies: retainOnly}),
+			restriction("prod", reclaimv1alpha1.VolumeReclaimRestrictionSpec{NamespaceSelector: prod, AllowedReclaimPolicies: []corev1.PersistentVolumeReclaimPolicy{corev1.PersistentVolumeReclaimDelete}}),
+		}, corev1.PersistentVolumeReclaimRetain,
+			"reclaim policy Retain is not allowed for Namespace payments by VolumeReclaimRestriction prod, allowed policies are Delete", []string{"broken"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invalid, denial := CheckRestrictions(tt.restrictions, tt.policy, "fast-ssd", ns)
+
+			var skipped []string
+			for _, restriction := range invalid {
+				if restriction.Err == nil {
+					t.Errorf("expected an error for invalid restriction %q", restriction.Restriction.Name)
+				}
+				skipped = append(skipped, restriction.Restriction.Name)
+			}
+			if !reflect.DeepEqual(skipped, tt.wantInvalid) {
+				t.Errorf("expected invalid restrictions %v, got %v", tt.wantInvalid, skipped)
+			}
+
+			denied := ""
+			if denial != nil {
+				denied = denial.Error()
+			}
+			if denied != tt.wantDenied {
+				t.Errorf("expected denial %q, got %q", tt.wantDenied, denied)
+			}
+		})
+	}
+}
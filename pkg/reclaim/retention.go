@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// RetainForAnnotation records how long a Persistent Volume is retained once it has been released, it's
+	// copied from the retention label of the claim (or its Namespace) while bound and can be set by admins
+	// on Persistent Volumes that are already released
+	RetainForAnnotation = "storage.k8s.twr.dev/retain-for"
+)
+
+// retentionUnits are the units ParseRetention accepts in addition to the ones supported by time.ParseDuration
+var retentionUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseRetention converts a retention value into a duration. Days ("30d") and weeks ("2w") are supported
+// in addition to Go durations ("36h").
+func ParseRetention(value string) (time.Duration, error) {
+	for unit, multiplier := range retentionUnits {
+		if !strings.HasSuffix(value, unit) {
+			continue
+		}
+
+		count, err := strconv.Atoi(strings.TrimSuffix(value, unit))
+		if err != nil || count <= 0 {
+			return 0, fmt.Errorf("invalid retention %q, must be a positive number of days (ie. 30d), weeks (ie. 2w), or a duration (ie. 36h)", value)
+		}
+		return time.Duration(count) * multiplier, nil
+	}
+
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("invalid retention %q, must be a positive number of days (ie. 30d), weeks (ie. 2w), or a duration (ie. 36h)", value)
+	}
+
+	return retention, nil
+}
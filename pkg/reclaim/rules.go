@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	reclaimv1alpha1 "twr.dev/volrec/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AppliedRuleAnnotation records the VolumeReclaimRule that set the reclaim policy on a Persistent Volume
+	AppliedRuleAnnotation = "storage.k8s.twr.dev/reclaim-rule"
+)
+
+// InvalidRule is a VolumeReclaimRule that can't be evaluated, ie. because of an invalid selector
+type InvalidRule struct {
+	Rule *reclaimv1alpha1.VolumeReclaimRule
+	Err  error
+}
+
+// MatchRule returns the winning VolumeReclaimRule for a Persistent Volume bound to the given claim, or nil if
+// no rule matches. Rules with a higher priority win, ties are broken by rule name. Rules that can't be evaluated
+// are skipped and returned separately, so a broken rule doesn't hold up the others.
+func MatchRule(rules []reclaimv1alpha1.VolumeReclaimRule, pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim, ns *corev1.Namespace) (*reclaimv1alpha1.VolumeReclaimRule, []InvalidRule) {
+	var (
+		matched []*reclaimv1alpha1.VolumeReclaimRule
+		invalid []InvalidRule
+	)
+
+	for i := range rules {
+		rule := &rules[i]
+
+		ok, err := ruleMatches(rule, pv, pvc, ns)
+		if err != nil {
+			invalid = append(invalid, InvalidRule{Rule: rule, Err: err})
+			continue
+		}
+		if ok {
+			matched = append(matched, rule)
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil, invalid
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].Spec.Priority != matched[j].Spec.Priority {
+			return matched[i].Spec.Priority > matched[j].Spec.Priority
+		}
+		return matched[i].Name < matched[j].Name
+	})
+
+	return matched[0], invalid
+}
+
+// ruleMatches reports whether every criteria of the rule matches
+func ruleMatches(rule *reclaimv1alpha1.VolumeReclaimRule, pv *corev1.PersistentVolume, pvc *corev1.PersistentVolumeClaim, ns *corev1.Namespace) (bool, error) {
+	if !storageClassMatches(rule.Spec.StorageClassNames, pv.Spec.StorageClassName) {
+		return false, nil
+	}
+
+	if ok, err := selectorMatches(rule.Spec.NamespaceSelector, ns.GetLabels()); !ok || err != nil {
+		return false, err
+	}
+
+	return selectorMatches(rule.Spec.PersistentVolumeClaimSelector, pvc.GetLabels())
+}
+
+// storageClassMatches reports whether the StorageClass is in the list, an empty list matches everything
+func storageClassMatches(names []string, storageClassName string) bool {
+	if len(names) == 0 {
+		return true
+	}
+
+	for _, name := range names {
+		if name == storageClassName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectorMatches reports whether the label set matches the selector, a nil selector matches everything
+func selectorMatches(selector *metav1.LabelSelector, set map[string]string) (bool, error) {
+	if selector == nil {
+		return true, nil
+	}
+
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, err
+	}
+
+	return s.Matches(labels.Set(set)), nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	reclaimv1alpha1 "twr.dev/volrec/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func rule(name string, priority int32, spec reclaimv1alpha1.VolumeReclaimRuleSpec) reclaimv1alpha1.VolumeReclaimRule {
+	spec.Priority = priority
+	return reclaimv1alpha1.VolumeReclaimRule{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: spec}
+}
+
+func TestMatchRule(t *testing.T) {
+	pv := &corev1.PersistentVolume{Spec: corev1.PersistentVolumeSpec{StorageClassName: "fast-ssd"}}
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "db"}}}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"environment": "prod"}}}
+
+	prod := &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "prod"}}
+	dev := &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "dev"}}
+	db := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}
+
+	invalid := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "environment", Operator: "Within"}}}
+
+	tests := []struct {
+		name        string
+		rules       []reclaimv1alpha1.VolumeReclaimRule
+		want        string
+		wantInvalid []string
+	}{
+		{"no rules", nil, "", nil},
+		{"storage class mismatch", []reclaimv1alpha1.VolumeReclaimRule{
+			rule("slow", 0, reclaimv1alpha1.VolumeReclaimRuleSpec{StorageClassNames: []string{"slow-hdd"}}),
+		}, "", nil},
+		{"namespace selector mismatch", []reclaimv1alpha1.VolumeReclaimRule{
+			rule("dev", 0, reclaimv1alpha1.VolumeReclaimRuleSpec{NamespaceSelector: dev}),
+		}, "", nil},
+		{"all criteria match", []reclaimv1alpha1.VolumeReclaimRule{
+			rule("prod-db", 0, reclaimv1alpha1.VolumeReclaimRuleSpec{StorageClassNames: []string{"fast-ssd"}, NamespaceSelector: prod, PersistentVolumeClaimSelector: db}),
+		}, "prod-db", nil},
+		{"highest priority wins", []reclaimv1alpha1.VolumeReclaimRule{
+			rule("catch-all", 0, reclaimv1alpha1.VolumeReclaimRuleSpec{}),
+			rule("prod", 10, reclaimv1alpha1.VolumeReclaimRuleSpec{NamespaceSelector: prod}),
+		}, "prod", nil},
+		{"ties broken by name", []reclaimv1alpha1.VolumeReclaimRule{
+			rule("b", 5, reclaimv1alpha1.VolumeReclaimRuleSpec{}),
+			rule("a", 5, reclaimv1alpha1.VolumeReclaimRuleSpec{}),
+		}, "a", nil},
+		{"invalid selector skipped", []reclaimv1alpha1.VolumeReclaimRule{
+			rule("broken", 20, reclaimv1alpha1.VolumeReclaimRuleSpec{NamespaceSelector: invalid}),
+			rule("prod", 10, reclaimv1alpha1.VolumeReclaimRuleSpec{NamespaceSelector: prod}),
+		}, "prod", []string{"broken"}},
+		{"only invalid rules", []reclaimv1alpha1.VolumeReclaimRule{
+			rule("broken", 0, reclaimv1alpha1.VolumeReclaimRuleSpec{PersistentVolumeClaimSelector: invalid}),
+		}, "", []string{"broken"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, invalid := MatchRule(tt.rules, pv, pvc, ns)
+
+			var skipped []string
+			for _, rule := range invalid {
+				if rule.Err == nil {
+					t.Errorf("expected an error for invalid rule %q", rule.Rule.Name)
+				}
+				skipped = append(skipped, rule.Rule.Name)
+			}
+			if !reflect.DeepEqual(skipped, tt.wantInvalid) {
+				t.Errorf("expected invalid rules %v, got %v", tt.wantInvalid, skipped)
+			}
+
+			name := ""
+			if got != nil {
+				name = got.Name
+			}
+			if name != tt.want {
+				t.Errorf("expected rule %q, got %q", tt.want, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	// SnapshotAnnotation records the VolumeSnapshot taken before volrec switched a Persistent Volume to the
+	// Delete reclaim policy
+	SnapshotAnnotation = "storage.k8s.twr.dev/snapshot"
+	// PendingSnapshotAnnotation records the VolumeSnapshot volrec is waiting on before switching a Persistent
+	// Volume to the Delete reclaim policy
+	PendingSnapshotAnnotation = "storage.k8s.twr.dev/pending-snapshot"
+	// SnapshotClassAnnotation sets the VolumeSnapshotClass used for the Persistent Volumes of a StorageClass,
+	// the default VolumeSnapshotClass of the CSI driver is used otherwise
+	SnapshotClassAnnotation = "storage.k8s.twr.dev/snapshot-class"
+)
+
+// snapshotNameSuffix is added to the claim name for the name of a VolumeSnapshot, followed by a random string
+const snapshotNameSuffix = "-volrec-"
+
+// maxSnapshotNameLength keeps VolumeSnapshot names usable as label values, like generated names
+const maxSnapshotNameLength = 63
+
+// NewSnapshotName returns a new name for a VolumeSnapshot of a claim. The name is chosen, and recorded, before the
+// snapshot is created so a snapshot is never created without volrec knowing about it.
+func NewSnapshotName(claim string) string {
+	random := utilrand.String(5)
+
+	base := claim + snapshotNameSuffix
+	if max := maxSnapshotNameLength - len(random); len(base) > max {
+		base = base[:max]
+	}
+
+	return base + random
+}
+
+// SnapshotStatus returns whether a VolumeSnapshot is readyToUse, and the message of its error if it failed
+func SnapshotStatus(snapshot *unstructured.Unstructured) (bool, string) {
+	ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+
+	if _, failed, _ := unstructured.NestedMap(snapshot.Object, "status", "error"); !failed {
+		return ready, ""
+	}
+	message, _, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message")
+	if message == "" {
+		message = "unknown error"
+	}
+
+	return ready, message
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestNewSnapshotName(t *testing.T) {
+	tests := []struct {
+		claim      string
+		wantPrefix string
+	}{
+		{"data", "data-volrec-"},
+		{strings.Repeat("a", 253), strings.Repeat("a", 58)},
+	}
+
+	for _, tt := range tests {
+		name := NewSnapshotName(tt.claim)
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			t.Errorf("NewSnapshotName(%q) = %q isn't valid: %v", tt.claim, name, errs)
+		}
+		if !strings.HasPrefix(name, tt.wantPrefix) {
+			t.Errorf("NewSnapshotName(%q) = %q, want prefix %q", tt.claim, name, tt.wantPrefix)
+		}
+	}
+
+	if NewSnapshotName("data") == NewSnapshotName("data") {
+		t.Error("NewSnapshotName() returned the same name twice")
+	}
+}
+
+func TestSnapshotStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      map[string]interface{}
+		wantReady   bool
+		wantMessage string
+	}{
+		{"no status", nil, false, ""},
+		{"pending", map[string]interface{}{"readyToUse": false}, false, ""},
+		{"ready", map[string]interface{}{"readyToUse": true}, true, ""},
+		{"failed", map[string]interface{}{"readyToUse": false, "error": map[string]interface{}{"message": "quota exceeded"}}, false, "quota exceeded"},
+		{"failed without message", map[string]interface{}{"error": map[string]interface{}{}}, false, "unknown error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			if tt.status != nil {
+				snapshot.Object["status"] = tt.status
+			}
+
+			ready, message := SnapshotStatus(snapshot)
+			if ready != tt.wantReady || message != tt.wantMessage {
+				t.Errorf("SnapshotStatus() = %v, %q, want %v, %q", ready, message, tt.wantReady, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// NamespaceLabelsAnnotation records the keys of the labels volrec copied onto a Persistent Volume from the
+	// Namespace of its claim (ie. the owner label), so they're removed once they're gone from the Namespace or
+	// copying them is disabled
+	NamespaceLabelsAnnotation = "storage.k8s.twr.dev/namespace-labels"
+	// NamespaceAnnotationsAnnotation records the keys of the annotations volrec copied onto a Persistent Volume
+	// from the Namespace of its claim
+	NamespaceAnnotationsAnnotation = "storage.k8s.twr.dev/namespace-annotations"
+)
+
+// GetTrackedKeys reads the keys recorded in a tracking annotation, ie. NamespaceLabelsAnnotation
+func GetTrackedKeys(obj metav1.Object, annotation string) []string {
+	value := obj.GetAnnotations()[annotation]
+	if value == "" {
+		return nil
+	}
+
+	return strings.Split(value, ",")
+}
+
+// SetTrackedKeys records keys in a tracking annotation, sorted so the annotation only changes with the keys.
+// No keys removes the annotation.
+func SetTrackedKeys(obj metav1.Object, annotation string, keys []string) {
+	annotations := obj.GetAnnotations()
+	if len(keys) == 0 {
+		delete(annotations, annotation)
+		obj.SetAnnotations(annotations)
+		return
+	}
+
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	annotations[annotation] = strings.Join(sorted, ",")
+
+	obj.SetAnnotations(annotations)
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reclaim
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestTrackedKeys(t *testing.T) {
+	pv := &corev1.PersistentVolume{}
+
+	if got := GetTrackedKeys(pv, NamespaceLabelsAnnotation); got != nil {
+		t.Errorf("GetTrackedKeys() = %v, want nil", got)
+	}
+
+	SetTrackedKeys(pv, NamespaceLabelsAnnotation, []string{"k8s.twr.dev/owning-namespace", "k8s.twr.dev/owner"})
+	if got := pv.Annotations[NamespaceLabelsAnnotation]; got != "k8s.twr.dev/owner,k8s.twr.dev/owning-namespace" {
+		t.Errorf("%s = %q, want sorted keys", NamespaceLabelsAnnotation, got)
+	}
+	if got, want := GetTrackedKeys(pv, NamespaceLabelsAnnotation), []string{"k8s.twr.dev/owner", "k8s.twr.dev/owning-namespace"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTrackedKeys() = %v, want %v", got, want)
+	}
+
+	SetTrackedKeys(pv, NamespaceLabelsAnnotation, nil)
+	if _, ok := pv.Annotations[NamespaceLabelsAnnotation]; ok {
+		t.Errorf("annotation %s wasn't removed", NamespaceLabelsAnnotation)
+	}
+}
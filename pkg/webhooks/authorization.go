@@ -0,0 +1,266 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	reclaimv1alpha1 "twr.dev/volrec/api/v1alpha1"
+	"twr.dev/volrec/pkg/config"
+	"twr.dev/volrec/pkg/reclaim"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+const (
+	// PersistentVolumeClaimAuthorizePath is the path the Persistent Volume Claim authorizing webhook is served on
+	PersistentVolumeClaimAuthorizePath = "/authorize-v1-persistentvolumeclaim"
+	// AuthorizationResource is the virtual resource reclaim policy changes are authorized against
+	AuthorizationResource = "persistentvolumeclaims"
+	// AuthorizationVerbPrefix is prepended to the lower case reclaim policy to build the verb to authorize,
+	// ie. "set-reclaim-delete"
+	AuthorizationVerbPrefix = "set-reclaim-"
+	// IsDefaultStorageClassAnnotation marks the StorageClass assigned to claims that don't request one
+	IsDefaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+	// BetaIsDefaultStorageClassAnnotation is the beta version of IsDefaultStorageClassAnnotation, still honoured
+	// by the API server
+	BetaIsDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// PersistentVolumeClaimAuthorizer checks that the reclaim policy requested through the reclaim policy label on
+// Persistent Volume Claims is allowed by the VolumeReclaimRestrictions, and that the requesting user is granted
+// destructive reclaim policy changes. It's registered with failurePolicy=Fail so the checks can't be bypassed
+// while volrec is unavailable.
+type PersistentVolumeClaimAuthorizer struct {
+	Client  client.Client
+	Log     logr.Logger
+	decoder *admission.Decoder
+}
+
+// +kubebuilder:webhook:path=/authorize-v1-persistentvolumeclaim,mutating=false,failurePolicy=fail,groups="",resources=persistentvolumeclaims,verbs=create;update,versions=v1,name=apersistentvolumeclaim.storage.k8s.twr.dev
+// +kubebuilder:webhook:path=/authorize-v1-persistentvolumeclaim,mutating=false,failurePolicy=fail,groups="",resources=persistentvolumeclaims,verbs=create;update,versions=v1,name=rpersistentvolumeclaim.storage.k8s.twr.dev
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// Handle rejects Persistent Volume Claims requesting a reclaim policy not allowed by a VolumeReclaimRestriction,
+// or changing an existing claim to a destructive policy without being granted it. Label values that aren't a
+// valid reclaim policy are left to the PersistentVolumeClaimValidator. A retention label ends with the PV being
+// switched to Delete once its retention expires, so it's checked as if it requested Delete.
+func (a *PersistentVolumeClaimAuthorizer) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := a.Log.WithValues("pvc", fmt.Sprintf("%s/%s", req.Namespace, req.Name), "operation", req.Operation)
+	cfg := config.Get()
+
+	var pvc corev1.PersistentVolumeClaim
+
+	if err := a.decoder.Decode(req, &pvc); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	reclaimPolicyFromPVCLabel, reclaimChanged, err := reclaimLabelChange(a.decoder, req, &pvc, cfg.ReclaimPolicyLabel)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	retentionChanged := false
+	if cfg.RetentionLabel != "" {
+		if _, retentionChanged, err = reclaimLabelChange(a.decoder, req, &pvc, cfg.RetentionLabel); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	if !reclaimChanged && !retentionChanged {
+		return admission.Allowed("Reclaim policy and retention labels unset or unchanged")
+	}
+
+	if reclaimChanged {
+		if reclaimPolicy, err := reclaim.ParsePolicy(reclaimPolicyFromPVCLabel); err != nil {
+			log.Info("Invalid reclaim policy label is left to the validating webhook", "reclaim-label", cfg.ReclaimPolicyLabel, "policy-from-pvc-label", reclaimPolicyFromPVCLabel)
+		} else if resp := a.authorizePolicy(ctx, log, cfg, req, &pvc, cfg.ReclaimPolicyLabel, reclaimPolicy); !resp.Allowed {
+			return resp
+		}
+	}
+
+	if retentionChanged {
+		if resp := a.authorizePolicy(ctx, log, cfg, req, &pvc, cfg.RetentionLabel, corev1.PersistentVolumeReclaimDelete); !resp.Allowed {
+			return resp
+		}
+	}
+
+	return admission.Allowed("")
+}
+
+// authorizePolicy checks that the reclaim policy requested through the label is allowed by the
+// VolumeReclaimRestrictions, and that the user is granted changing an existing claim to it if it's destructive
+func (a *PersistentVolumeClaimAuthorizer) authorizePolicy(ctx context.Context, log logr.Logger, cfg config.ControllerConfig, req admission.Request, pvc *corev1.PersistentVolumeClaim, label string, reclaimPolicy corev1.PersistentVolumeReclaimPolicy) admission.Response {
+	var (
+		ns           corev1.Namespace
+		restrictions reclaimv1alpha1.VolumeReclaimRestrictionList
+	)
+
+	if err := a.Client.List(ctx, &restrictions); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	if len(restrictions.Items) > 0 {
+		if err := a.Client.Get(ctx, client.ObjectKey{Name: req.Namespace}, &ns); err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+
+		storageClassName, err := claimStorageClass(ctx, a.Client, pvc)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+
+		invalid, denial := reclaim.CheckRestrictions(restrictions.Items, reclaimPolicy, storageClassName, &ns)
+		for _, skipped := range invalid {
+			log.Error(skipped.Err, "Invalid VolumeReclaimRestriction", "restriction", skipped.Restriction.Name)
+		}
+		if denial != nil {
+			log.Info("Denying restricted reclaim policy", "label", label, "policy", reclaimPolicy, "reason", denial.Error())
+			return admission.Denied(fmt.Sprintf("label %q: %v", label, denial))
+		}
+	}
+
+	// Changing an existing claim to a destructive policy requires a separate grant from plain PVC edit
+	if cfg.AuthorizeReclaimChanges && req.Operation == admissionv1beta1.Update && reclaim.IsDestructive(reclaimPolicy) {
+		allowed, reason, err := a.authorize(ctx, req, reclaimPolicy)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if !allowed {
+			log.Info("Denying unauthorized reclaim policy change", "label", label, "user", req.UserInfo.Username, "verb", authorizationVerb(reclaimPolicy), "reason", reason)
+			return admission.Denied(fmt.Sprintf("label %q: user %q is not allowed to %q %s.%s in Namespace %q",
+				label, req.UserInfo.Username, authorizationVerb(reclaimPolicy), AuthorizationResource, reclaimv1alpha1.GroupVersion.Group, req.Namespace))
+		}
+	}
+
+	return admission.Allowed("")
+}
+
+// InjectDecoder injects the admission decoder into the authorizer
+func (a *PersistentVolumeClaimAuthorizer) InjectDecoder(d *admission.Decoder) error {
+	a.decoder = d
+	return nil
+}
+
+// SetupWebhookWithManager registers the authorizing webhook with the Controller Manager's webhook server
+func (a *PersistentVolumeClaimAuthorizer) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(PersistentVolumeClaimAuthorizePath, &webhook.Admission{Handler: a})
+	return nil
+}
+
+// authorizationVerb returns the verb a user needs to be granted to request the reclaim policy
+func authorizationVerb(policy corev1.PersistentVolumeReclaimPolicy) string {
+	return AuthorizationVerbPrefix + strings.ToLower(string(policy))
+}
+
+// authorize issues a SubjectAccessReview checking whether the user making the admission request may set the
+// reclaim policy on the claim
+func (a *PersistentVolumeClaimAuthorizer) authorize(ctx context.Context, req admission.Request, policy corev1.PersistentVolumeReclaimPolicy) (bool, string, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(req.UserInfo.Extra))
+	for key, value := range req.UserInfo.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   req.UserInfo.Username,
+			Groups: req.UserInfo.Groups,
+			UID:    req.UserInfo.UID,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: req.Namespace,
+				Name:      req.Name,
+				Group:     reclaimv1alpha1.GroupVersion.Group,
+				Resource:  AuthorizationResource,
+				Verb:      authorizationVerb(policy),
+			},
+		},
+	}
+
+	if err := a.Client.Create(ctx, sar); err != nil {
+		return false, "", err
+	}
+
+	return sar.Status.Allowed, sar.Status.Reason, nil
+}
+
+// claimStorageClass returns the StorageClass restrictions are checked against for a claim, matching what the
+// controller checks once the claim is bound: the class of the bound PV, the class requested by the claim, or the
+// default StorageClass the API server assigns to claims that don't request one.
+func claimStorageClass(ctx context.Context, c client.Reader, pvc *corev1.PersistentVolumeClaim) (string, error) {
+	if pvc.Spec.VolumeName != "" {
+		var pv corev1.PersistentVolume
+
+		err := c.Get(ctx, client.ObjectKey{Name: pvc.Spec.VolumeName}, &pv)
+		if err == nil {
+			return pv.Spec.StorageClassName, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return "", err
+		}
+	}
+
+	if pvc.Spec.StorageClassName != nil {
+		return *pvc.Spec.StorageClassName, nil
+	}
+
+	var storageClasses storagev1.StorageClassList
+
+	if err := c.List(ctx, &storageClasses); err != nil {
+		return "", err
+	}
+
+	// With more than one default the newest wins, same as recent releases of the DefaultStorageClass admission plugin
+	var defaultClass *storagev1.StorageClass
+	for i := range storageClasses.Items {
+		sc := &storageClasses.Items[i]
+		if !isDefaultStorageClass(sc) {
+			continue
+		}
+		if defaultClass == nil || sc.CreationTimestamp.After(defaultClass.CreationTimestamp.Time) {
+			defaultClass = sc
+		}
+	}
+	if defaultClass == nil {
+		return "", nil
+	}
+
+	return defaultClass.Name, nil
+}
+
+// isDefaultStorageClass reports whether a StorageClass is annotated as the cluster default
+func isDefaultStorageClass(sc *storagev1.StorageClass) bool {
+	for _, annotation := range []string{IsDefaultStorageClassAnnotation, BetaIsDefaultStorageClassAnnotation} {
+		if sc.GetAnnotations()[annotation] == "true" {
+			return true
+		}
+	}
+
+	return false
+}
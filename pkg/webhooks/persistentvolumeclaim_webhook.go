@@ -0,0 +1,108 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"twr.dev/volrec/pkg/config"
+	"twr.dev/volrec/pkg/reclaim"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PersistentVolumeClaimValidatePath is the path the Persistent Volume Claim validating webhook is served on
+const PersistentVolumeClaimValidatePath = "/validate-v1-persistentvolumeclaim"
+
+// PersistentVolumeClaimValidator validates the format of the reclaim policy label on Persistent Volume Claims
+type PersistentVolumeClaimValidator struct {
+	Log     logr.Logger
+	decoder *admission.Decoder
+}
+
+// +kubebuilder:webhook:path=/validate-v1-persistentvolumeclaim,mutating=false,failurePolicy=ignore,groups="",resources=persistentvolumeclaims,verbs=create;update,versions=v1,name=vpersistentvolumeclaim.storage.k8s.twr.dev
+
+// Handle rejects Persistent Volume Claims that request an unsupported Reclaim Policy through the reclaim
+// policy label. Whether the policy is allowed is checked by the PersistentVolumeClaimAuthorizer.
+func (v *PersistentVolumeClaimValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := v.Log.WithValues("pvc", fmt.Sprintf("%s/%s", req.Namespace, req.Name), "operation", req.Operation)
+	cfg := config.Get()
+
+	var pvc corev1.PersistentVolumeClaim
+
+	if err := v.decoder.Decode(req, &pvc); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	reclaimPolicyFromPVCLabel, changed, err := reclaimLabelChange(v.decoder, req, &pvc, cfg.ReclaimPolicyLabel)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if !changed {
+		return admission.Allowed("Reclaim policy label unset or unchanged")
+	}
+
+	if _, err := reclaim.ParsePolicy(reclaimPolicyFromPVCLabel); err != nil {
+		log.Info("Denying invalid reclaim policy label", "reclaim-label", cfg.ReclaimPolicyLabel, "policy-from-pvc-label", reclaimPolicyFromPVCLabel)
+		return admission.Denied(fmt.Sprintf("label %q: %v", cfg.ReclaimPolicyLabel, err))
+	}
+
+	return admission.Allowed("")
+}
+
+// InjectDecoder injects the admission decoder into the validator
+func (v *PersistentVolumeClaimValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// SetupWebhookWithManager registers the validating webhook with the Controller Manager's webhook server
+func (v *PersistentVolumeClaimValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(PersistentVolumeClaimValidatePath, &webhook.Admission{Handler: v})
+	return nil
+}
+
+// reclaimLabelChange returns the reclaim policy (or retention) label on the claim being admitted, and whether it
+// needs to be checked. Only changes to the label are checked so that unrelated updates (ie. finalizer removal) are
+// never blocked. An empty label value is treated as unset, same as the controller does.
+func reclaimLabelChange(decoder *admission.Decoder, req admission.Request, pvc *corev1.PersistentVolumeClaim, label string) (string, bool, error) {
+	value := pvc.GetLabels()[label]
+	if value == "" {
+		return "", false, nil
+	}
+
+	if req.Operation == admissionv1beta1.Update {
+		var oldPVC corev1.PersistentVolumeClaim
+
+		if err := decoder.DecodeRaw(req.OldObject, &oldPVC); err != nil {
+			return "", false, err
+		}
+
+		if oldPVC.GetLabels()[label] == value {
+			return value, false, nil
+		}
+	}
+
+	return value, true, nil
+}
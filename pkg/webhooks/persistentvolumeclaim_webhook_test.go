@@ -0,0 +1,195 @@
+/*
+Copyright 2021 The WebRoot.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	reclaimv1alpha1 "twr.dev/volrec/api/v1alpha1"
+	"twr.dev/volrec/pkg/config"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+const (
+	testReclaimLabel   = "storage.k8s.twr.dev/reclaim-policy"
+	testRetentionLabel = "storage.k8s.twr.dev/retain-for"
+)
+
+func pvcRaw(t *testing.T, namespace string, labels map[string]string) runtime.RawExtension {
+	pvc := corev1.PersistentVolumeClaim{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: namespace, Labels: labels},
+	}
+
+	raw, err := json.Marshal(pvc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestPersistentVolumeClaimWebhooks(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = reclaimv1alpha1.AddToScheme(scheme)
+
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test1"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod1", Labels: map[string]string{"environment": "prod"}}},
+		&reclaimv1alpha1.VolumeReclaimRestriction{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod-retain-only"},
+			Spec: reclaimv1alpha1.VolumeReclaimRestrictionSpec{
+				NamespaceSelector:      &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "prod"}},
+				AllowedReclaimPolicies: []corev1.PersistentVolumeReclaimPolicy{corev1.PersistentVolumeReclaimRetain},
+			},
+		},
+	)
+
+	v := &PersistentVolumeClaimValidator{Log: zap.New(zap.UseDevMode(true))}
+	if err := v.InjectDecoder(decoder); err != nil {
+		t.Fatal(err)
+	}
+	a := &PersistentVolumeClaimAuthorizer{Client: c, Log: zap.New(zap.UseDevMode(true))}
+	if err := a.InjectDecoder(decoder); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		operation admissionv1beta1.Operation
+		oldLabels map[string]string
+		newLabels map[string]string
+		authorize bool
+		valid     bool
+		allowed   bool
+	}{
+		{"create without label", "test1", admissionv1beta1.Create, nil, map[string]string{}, false, true, true},
+		{"create with valid label", "test1", admissionv1beta1.Create, nil, map[string]string{testReclaimLabel: "Retain"}, false, true, true},
+		{"create with empty label", "test1", admissionv1beta1.Create, nil, map[string]string{testReclaimLabel: ""}, false, true, true},
+		{"update to empty label", "prod1", admissionv1beta1.Update, map[string]string{testReclaimLabel: "Retain"}, map[string]string{testReclaimLabel: ""}, true, true, true},
+		{"create with invalid label", "test1", admissionv1beta1.Create, nil, map[string]string{testReclaimLabel: "retain"}, false, false, true},
+		{"update to invalid label", "test1", admissionv1beta1.Update, map[string]string{testReclaimLabel: "Retain"}, map[string]string{testReclaimLabel: "Keep"}, false, false, true},
+		{"update to valid label", "test1", admissionv1beta1.Update, map[string]string{testReclaimLabel: "Retain"}, map[string]string{testReclaimLabel: "Delete"}, false, true, true},
+		{"update with unchanged invalid label", "test1", admissionv1beta1.Update, map[string]string{testReclaimLabel: "Keep"}, map[string]string{testReclaimLabel: "Keep"}, false, true, true},
+		{"restricted namespace allowed policy", "prod1", admissionv1beta1.Create, nil, map[string]string{testReclaimLabel: "Retain"}, false, true, true},
+		{"restricted namespace disallowed policy", "prod1", admissionv1beta1.Update, map[string]string{testReclaimLabel: "Retain"}, map[string]string{testReclaimLabel: "Delete"}, false, true, false},
+		{"unauthorized update to destructive policy", "test1", admissionv1beta1.Update, map[string]string{testReclaimLabel: "Retain"}, map[string]string{testReclaimLabel: "Delete"}, true, true, false},
+		{"update to non-destructive policy with authorization", "test1", admissionv1beta1.Update, map[string]string{testReclaimLabel: "Delete"}, map[string]string{testReclaimLabel: "Retain"}, true, true, true},
+		{"create with destructive policy with authorization", "test1", admissionv1beta1.Create, nil, map[string]string{testReclaimLabel: "Delete"}, true, true, true},
+		{"create with retention", "test1", admissionv1beta1.Create, nil, map[string]string{testRetentionLabel: "30d"}, true, true, true},
+		{"create with retention in restricted namespace", "prod1", admissionv1beta1.Create, nil, map[string]string{testRetentionLabel: "30d"}, false, true, false},
+		{"unauthorized update adding retention", "test1", admissionv1beta1.Update, map[string]string{}, map[string]string{testRetentionLabel: "1h"}, true, true, false},
+		{"unauthorized update changing retention", "test1", admissionv1beta1.Update, map[string]string{testRetentionLabel: "30d"}, map[string]string{testRetentionLabel: "1h"}, true, true, false},
+		{"update with unchanged retention", "test1", admissionv1beta1.Update, map[string]string{testRetentionLabel: "30d"}, map[string]string{testRetentionLabel: "30d"}, true, true, true},
+		{"update removing retention", "prod1", admissionv1beta1.Update, map[string]string{testRetentionLabel: "30d"}, map[string]string{}, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Set(config.ControllerConfig{ReclaimPolicyLabel: testReclaimLabel, RetentionLabel: testRetentionLabel, AuthorizeReclaimChanges: tt.authorize})
+
+			req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Operation: tt.operation,
+				Name:      "data",
+				Namespace: tt.namespace,
+				Object:    pvcRaw(t, tt.namespace, tt.newLabels),
+				UserInfo:  authenticationv1.UserInfo{Username: "developer"},
+			}}
+			if tt.oldLabels != nil {
+				req.OldObject = pvcRaw(t, tt.namespace, tt.oldLabels)
+			}
+
+			resp := v.Handle(context.Background(), req)
+			if resp.Allowed != tt.valid {
+				t.Errorf("expected valid=%v, got %v (%v)", tt.valid, resp.Allowed, resp.Result)
+			}
+
+			resp = a.Handle(context.Background(), req)
+			if resp.Allowed != tt.allowed {
+				t.Errorf("expected allowed=%v, got %v (%v)", tt.allowed, resp.Allowed, resp.Result)
+			}
+		})
+	}
+}
+
+func TestClaimStorageClass(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	storageClass := func(name string, created time.Time, annotations map[string]string) *storagev1.StorageClass {
+		return &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created), Annotations: annotations}}
+	}
+	now := time.Now()
+	standard := storageClass("standard", now.Add(-time.Hour), map[string]string{IsDefaultStorageClassAnnotation: "true"})
+	legacy := storageClass("legacy", now.Add(-2*time.Hour), map[string]string{BetaIsDefaultStorageClassAnnotation: "true"})
+	fast := storageClass("fast-ssd", now, nil)
+	pv := &corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-1"}, Spec: corev1.PersistentVolumeSpec{StorageClassName: "slow-hdd"}}
+
+	className := func(name string) *string { return &name }
+
+	tests := []struct {
+		name             string
+		objects          []runtime.Object
+		storageClassName *string
+		volumeName       string
+		want             string
+	}{
+		{"requested class", []runtime.Object{standard, fast}, className("fast-ssd"), "", "fast-ssd"},
+		{"no class requested", []runtime.Object{standard, fast}, className(""), "", ""},
+		{"default class", []runtime.Object{standard, fast}, nil, "", "standard"},
+		{"beta default class", []runtime.Object{legacy, fast}, nil, "", "legacy"},
+		{"newest default class wins", []runtime.Object{legacy, standard, fast}, nil, "", "standard"},
+		{"no default class", []runtime.Object{fast}, nil, "", ""},
+		{"bound PV class", []runtime.Object{standard, pv}, nil, "pv-1", "slow-hdd"},
+		{"bound PV missing", []runtime.Object{standard}, className("fast-ssd"), "pv-1", "fast-ssd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClientWithScheme(scheme, tt.objects...)
+			pvc := &corev1.PersistentVolumeClaim{Spec: corev1.PersistentVolumeClaimSpec{StorageClassName: tt.storageClassName, VolumeName: tt.volumeName}}
+
+			got, err := claimStorageClass(context.Background(), c, pvc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("claimStorageClass() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}